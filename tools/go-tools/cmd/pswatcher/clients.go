@@ -0,0 +1,478 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const defaultRestartGrace = 30 * time.Second
+
+// processSpec is the launch recipe for one managed process -- the
+// coordinator or a client -- recorded at startup so a later restart or
+// preStop hook can reproduce its command/env/host exactly.
+type processSpec struct {
+	cmdCfg        CommandConfig
+	isCoordinator bool
+	env           []string
+	useSSH        bool
+	serverHost    string
+	workingDir    string
+	port          int
+}
+
+var (
+	processSpecsMu sync.Mutex
+	processSpecs   = make(map[string]processSpec)
+)
+
+func registerProcessSpec(name string, spec processSpec) {
+	processSpecsMu.Lock()
+	processSpecs[name] = spec
+	processSpecsMu.Unlock()
+}
+
+func getProcessSpec(name string) (processSpec, bool) {
+	processSpecsMu.Lock()
+	defer processSpecsMu.Unlock()
+	spec, ok := processSpecs[name]
+	return spec, ok
+}
+
+// forgetProcessSpec removes name's recorded launch recipe, e.g. once its
+// server has been removed by a config reload, so a stale restart/exit
+// request for it fails with "unknown client" instead of respawning it.
+func forgetProcessSpec(name string) {
+	processSpecsMu.Lock()
+	delete(processSpecs, name)
+	processSpecsMu.Unlock()
+}
+
+// knownClientNames returns every client (not the coordinator)
+// startClientsForServer has recorded a processSpec for, sorted so a rolling
+// restart makes a stable, repeatable pass over the cluster.
+func knownClientNames() []string {
+	processSpecsMu.Lock()
+	defer processSpecsMu.Unlock()
+	names := make([]string, 0, len(processSpecs))
+	for name := range processSpecs {
+		if name == "coordinator" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// terminationSignals maps the signal names accepted by the restart/
+// rolling-restart HTTP endpoints to their os/ssh equivalents. Kept separate
+// from drain.go's drainSignals since these name plain termination signals,
+// not a trainer's custom "finish up and drain" signal.
+var terminationSignals = map[string]struct {
+	os  syscall.Signal
+	ssh ssh.Signal
+}{
+	"SIGTERM": {syscall.SIGTERM, ssh.SIGTERM},
+	"SIGINT":  {syscall.SIGINT, ssh.SIGINT},
+	"SIGHUP":  {syscall.SIGHUP, ssh.SIGHUP},
+	"SIGQUIT": {syscall.SIGQUIT, ssh.SIGQUIT},
+	"SIGUSR1": {syscall.SIGUSR1, ssh.SIGUSR1},
+	"SIGUSR2": {syscall.SIGUSR2, ssh.SIGUSR2},
+	"SIGKILL": {syscall.SIGKILL, ssh.SIGKILL},
+}
+
+func terminationSignal(name string) (struct {
+	os  syscall.Signal
+	ssh ssh.Signal
+}, bool) {
+	if name == "" {
+		name = "SIGTERM"
+	}
+	sig, ok := terminationSignals[name]
+	return sig, ok
+}
+
+// sshSignalByNumber maps the handful of signal numbers exitClient can send
+// to a remote client to the named ssh.Signal golang.org/x/crypto/ssh requires -- the
+// SSH protocol only has named signals, so an arbitrary numeric signal can't
+// be forwarded to a remote session the way it can to a local process group.
+var sshSignalByNumber = map[int]ssh.Signal{
+	1:  ssh.SIGHUP,
+	2:  ssh.SIGINT,
+	3:  ssh.SIGQUIT,
+	6:  ssh.SIGABRT,
+	8:  ssh.SIGFPE,
+	9:  ssh.SIGKILL,
+	10: ssh.SIGUSR1,
+	11: ssh.SIGSEGV,
+	12: ssh.SIGUSR2,
+	13: ssh.SIGPIPE,
+	14: ssh.SIGALRM,
+	15: ssh.SIGTERM,
+}
+
+func signalLocalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	if cmd.Process == nil {
+		return fmt.Errorf("process has no PID")
+	}
+	pgid, err := syscall.Getpgid(cmd.Process.Pid)
+	if err != nil {
+		pgid = cmd.Process.Pid
+	}
+	return syscall.Kill(-pgid, sig)
+}
+
+// killGraceAfterSIGKILL bounds how long waitForProcessExit keeps polling
+// after it has already sent SIGKILL. Until then a caller does not return (and
+// let a restart spawn a replacement) while the old process's exit is still
+// racing to remove its runningProcesses/runningSessions entry -- if that
+// removal landed after the replacement's own entry was written, it would
+// delete the replacement's entry instead and leave it untracked. Once this
+// bound passes, waitForProcessExit returns even if the entry is still there.
+const killGraceAfterSIGKILL = 5 * time.Second
+
+// waitForProcessExit polls the process/session tables until name's entry is
+// gone -- meaning its runProcess/runSSHProcess onExit callback already ran,
+// so it's safe for a caller to reuse the name -- or timeout elapses, in
+// which case whatever's left is sent SIGKILL and waited on a little longer.
+func waitForProcessExit(name string, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	killSent := false
+
+	for {
+		processMutex.RLock()
+		cmd, isLocal := runningProcesses[name]
+		session, isSSH := runningSessions[name]
+		processMutex.RUnlock()
+
+		if !isLocal && !isSSH {
+			return
+		}
+
+		now := time.Now()
+		if !killSent && now.After(deadline) {
+			fmt.Printf("[%s] Grace period elapsed, sending SIGKILL\n", name)
+			if isLocal && cmd.Process != nil {
+				if pgid, err := syscall.Getpgid(cmd.Process.Pid); err == nil {
+					syscall.Kill(-pgid, syscall.SIGKILL)
+				} else {
+					cmd.Process.Kill()
+				}
+			}
+			if isSSH {
+				if spec, ok := getProcessSpec(name); ok {
+					if err := signalRemoteProcessGroup(spec.serverHost, name, ssh.SIGKILL); err != nil {
+						fmt.Printf("[%s] Failed to forward SIGKILL, closing session instead: %v\n", name, err)
+						session.Close()
+					}
+				} else {
+					session.Close()
+				}
+			}
+			killSent = true
+			deadline = now.Add(killGraceAfterSIGKILL)
+		} else if killSent && now.After(deadline) {
+			fmt.Printf("[%s] Still present after SIGKILL, giving up waiting\n", name)
+			return
+		}
+		time.Sleep(drainPollInterval)
+	}
+}
+
+// waitForHealthy polls name's metrics port until a scrape succeeds or
+// timeout elapses, returning whether it came up healthy in time.
+func waitForHealthy(name string, timeout time.Duration) bool {
+	spec, ok := getProcessSpec(name)
+	if !ok {
+		return false
+	}
+	host := getMetricsHost(spec.serverHost)
+	metricsCfg := getConfig().Metrics
+	client := newMetricsHTTPClient(metricsCfg)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, err := scrapeMetrics(client, host, spec.port, metricsCfg); err == nil {
+			updateProcessState(name, "status", "running", nil)
+			broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"running"}`, name))
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(drainPollInterval)
+	}
+}
+
+// restartClient stops name (signal escalating to SIGKILL after grace) and
+// respawns it from its recorded processSpec, re-arming checkMetricsPort. It
+// does not wait for the respawned process to report healthy; rollingRestart
+// does that itself between batches.
+func restartClient(name string, grace time.Duration, signalName string) error {
+	spec, ok := getProcessSpec(name)
+	if !ok {
+		return fmt.Errorf("no client %q is known to this watcher", name)
+	}
+	sig, ok := terminationSignal(signalName)
+	if !ok {
+		return fmt.Errorf("unknown signal %q", signalName)
+	}
+
+	processMutex.RLock()
+	cmd, isLocal := runningProcesses[name]
+	_, isSSH := runningSessions[name]
+	processMutex.RUnlock()
+
+	updateProcessState(name, "status", "restarting", nil)
+	broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"restarting"}`, name))
+
+	if isLocal || isSSH {
+		// Only mark this an intentional stop once we know there's actually a
+		// running process to stop -- otherwise the flag sits there and gets
+		// mistakenly consumed by the next unrelated exit of the process we're
+		// about to spawn below.
+		markIntentionalStop(name)
+
+		runPreStopHook(name, spec.cmdCfg)
+
+		switch {
+		case isLocal:
+			if err := signalLocalProcessGroup(cmd, sig.os); err != nil {
+				fmt.Printf("[%s] Failed to send %s: %v\n", name, signalName, err)
+			}
+		case isSSH:
+			if err := signalRemoteProcessGroup(spec.serverHost, name, sig.ssh); err != nil {
+				fmt.Printf("[%s] Failed to forward %s: %v\n", name, signalName, err)
+			}
+		}
+
+		updateProcessState(name, "status", "draining", nil)
+		broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"draining"}`, name))
+		waitForProcessExit(name, grace)
+	}
+
+	startSupervised(runCtx, name, spec.cmdCfg, spec.isCoordinator, spec.env, spec.useSSH, spec.serverHost, spec.workingDir)
+	go checkMetricsPort(runCtx, name, spec.serverHost, spec.port)
+
+	updateProcessState(name, "status", "up", nil)
+	broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"up"}`, name))
+	return nil
+}
+
+// exitClient sends signal number code to name -- marking it intentionally
+// stopped so the supervisor loop does not respawn it -- then waits up to
+// timeout before escalating to SIGKILL. Unlike restartClient it does not
+// spawn a replacement.
+func exitClient(name string, code int, timeout time.Duration) error {
+	processMutex.RLock()
+	cmd, isLocal := runningProcesses[name]
+	_, isSSH := runningSessions[name]
+	processMutex.RUnlock()
+
+	if !isLocal && !isSSH {
+		return fmt.Errorf("client %q is not running", name)
+	}
+
+	markIntentionalStop(name)
+	updateProcessState(name, "status", "killing", nil)
+	broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"killing"}`, name))
+
+	if isLocal {
+		if err := signalLocalProcessGroup(cmd, syscall.Signal(code)); err != nil {
+			return fmt.Errorf("send signal %d to %s: %w", code, name, err)
+		}
+	} else {
+		sig, ok := sshSignalByNumber[code]
+		if !ok {
+			return fmt.Errorf("signal %d has no SSH equivalent for a remote client", code)
+		}
+		spec, ok := getProcessSpec(name)
+		if !ok {
+			return fmt.Errorf("no recorded launch spec for %s", name)
+		}
+		if err := signalRemoteProcessGroup(spec.serverHost, name, sig); err != nil {
+			return fmt.Errorf("send signal %d to %s: %w", code, name, err)
+		}
+	}
+
+	updateProcessState(name, "status", "draining", nil)
+	broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"draining"}`, name))
+	waitForProcessExit(name, timeout)
+	return nil
+}
+
+// rollingRestart restarts names in batches of batchSize, waiting for every
+// client in a batch to report a healthy metrics scrape before starting the
+// next batch. If any client in a batch is not healthy within grace, the
+// rollout stops there, so a bad binary is caught on the first batch instead
+// of rolled out across the whole cluster.
+func rollingRestart(names []string, batchSize int, grace time.Duration) {
+	for start := 0; start < len(names); start += batchSize {
+		end := start + batchSize
+		if end > len(names) {
+			end = len(names)
+		}
+		batch := names[start:end]
+		fmt.Printf("[rolling-restart] Restarting batch %v\n", batch)
+
+		var wg sync.WaitGroup
+		for _, name := range batch {
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+				if err := restartClient(name, grace, ""); err != nil {
+					fmt.Printf("[rolling-restart] %s: %v\n", name, err)
+				}
+			}(name)
+		}
+		wg.Wait()
+
+		healthy := true
+		for _, name := range batch {
+			if !waitForHealthy(name, grace) {
+				fmt.Printf("[rolling-restart] %s did not report healthy within %s\n", name, grace)
+				healthy = false
+			}
+		}
+		if !healthy {
+			fmt.Println("[rolling-restart] Aborted: batch did not come up healthy, remaining clients were not restarted.")
+			return
+		}
+	}
+	fmt.Println("[rolling-restart] Complete.")
+}
+
+// handleClientActionHTTP implements POST /clients/{name}/restart and
+// POST /clients/{name}/exit, dispatching on the path suffix since both share
+// the "/clients/{name}/..." prefix.
+func handleClientActionHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/clients/")
+	switch {
+	case strings.HasSuffix(path, "/restart"):
+		handleClientRestartHTTP(w, r, strings.TrimSuffix(path, "/restart"))
+	case strings.HasSuffix(path, "/exit"):
+		handleClientExitHTTP(w, r, strings.TrimSuffix(path, "/exit"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleClientRestartHTTP implements POST /clients/{name}/restart?grace=<duration>&signal=<name>.
+func handleClientRestartHTTP(w http.ResponseWriter, r *http.Request, name string) {
+	if name == "" {
+		http.Error(w, "missing client name", http.StatusBadRequest)
+		return
+	}
+	spec, ok := getProcessSpec(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown client %q", name), http.StatusNotFound)
+		return
+	}
+
+	grace := terminationGrace(spec.cmdCfg)
+	if g := r.URL.Query().Get("grace"); g != "" {
+		parsed, err := time.ParseDuration(g)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid grace: %v", err), http.StatusBadRequest)
+			return
+		}
+		grace = parsed
+	}
+
+	signalName := r.URL.Query().Get("signal")
+	if _, ok := terminationSignal(signalName); !ok {
+		http.Error(w, fmt.Sprintf("unknown signal %q", signalName), http.StatusBadRequest)
+		return
+	}
+
+	fmt.Printf("Received POST /clients/%s/restart (grace=%s, signal=%s)\n", name, grace, signalName)
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintln(w, "restart initiated")
+
+	go restartClient(name, grace, signalName)
+}
+
+// handleClientExitHTTP implements POST /clients/{name}/exit?code=<signal-number>&timeout=<duration>.
+func handleClientExitHTTP(w http.ResponseWriter, r *http.Request, name string) {
+	if name == "" {
+		http.Error(w, "missing client name", http.StatusBadRequest)
+		return
+	}
+
+	code, err := strconv.Atoi(r.URL.Query().Get("code"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid code: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	timeout := defaultRestartGrace
+	if t := r.URL.Query().Get("timeout"); t != "" {
+		parsed, err := time.ParseDuration(t)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid timeout: %v", err), http.StatusBadRequest)
+			return
+		}
+		timeout = parsed
+	}
+
+	fmt.Printf("Received POST /clients/%s/exit (code=%d, timeout=%s)\n", name, code, timeout)
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintln(w, "exit initiated")
+
+	go func() {
+		if err := exitClient(name, code, timeout); err != nil {
+			fmt.Printf("[%s] exit failed: %v\n", name, err)
+		}
+	}()
+}
+
+// handleRollingRestartHTTP implements POST /rolling-restart?batch=<N>&grace=<duration>,
+// restarting every known client in batches of N, waiting for each batch to
+// report healthy metrics before moving to the next.
+func handleRollingRestartHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	batchSize := 1
+	if b := r.URL.Query().Get("batch"); b != "" {
+		n, err := strconv.Atoi(b)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid batch", http.StatusBadRequest)
+			return
+		}
+		batchSize = n
+	}
+
+	grace := terminationGrace(getConfig().Commands["client"])
+	if g := r.URL.Query().Get("grace"); g != "" {
+		parsed, err := time.ParseDuration(g)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid grace: %v", err), http.StatusBadRequest)
+			return
+		}
+		grace = parsed
+	}
+
+	names := knownClientNames()
+	fmt.Printf("Received POST /rolling-restart (batch=%d, grace=%s, %d clients)\n", batchSize, grace, len(names))
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "rolling restart of %d clients initiated\n", len(names))
+
+	go rollingRestart(names, batchSize, grace)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultDrainSignal        = "SIGUSR1"
+	defaultGracePeriodSeconds = 30
+	drainPollInterval         = 250 * time.Millisecond
+)
+
+// drainSignals maps the signal names accepted in CommandConfig.DrainSignal
+// to their os/ssh equivalents.
+var drainSignals = map[string]struct {
+	os  syscall.Signal
+	ssh ssh.Signal
+}{
+	"SIGUSR1": {syscall.SIGUSR1, ssh.SIGUSR1},
+	"SIGUSR2": {syscall.SIGUSR2, ssh.SIGUSR2},
+	"SIGHUP":  {syscall.SIGHUP, ssh.SIGHUP},
+}
+
+var (
+	drainedMu sync.Mutex
+	drained   = make(map[string]bool)
+)
+
+// markDrained records that name's process has told us (via the "drained"
+// WS message) that it finished its in-flight work and is ready to exit, so
+// waitForDrain's poll loop for it returns immediately instead of running
+// out its full grace period.
+func markDrained(name string) {
+	drainedMu.Lock()
+	drained[name] = true
+	drainedMu.Unlock()
+}
+
+func isDrained(name string) bool {
+	drainedMu.Lock()
+	defer drainedMu.Unlock()
+	return drained[name]
+}
+
+func resetDrained() {
+	drainedMu.Lock()
+	drained = make(map[string]bool)
+	drainedMu.Unlock()
+}
+
+// commandConfigFor returns the CommandConfig a running process was started
+// from: "coordinator" itself, or "client" for every client process (named
+// "<server>-clientN" by startClientsForServer).
+func commandConfigFor(name string) CommandConfig {
+	cfg := getConfig()
+	if name == "coordinator" {
+		return cfg.Commands["coordinator"]
+	}
+	return cfg.Commands["client"]
+}
+
+// runDrainPhase sends each running process its configured drain signal and
+// waits for it to either report itself drained over WS or run out its
+// GracePeriodSeconds, whichever comes first, broadcasting a "draining"/
+// "drained" status for each over the existing WS pipeline so the dashboard
+// can show progress. Callers proceed to the SIGTERM/SIGKILL teardown once
+// this returns - a process that didn't report drained in time is simply
+// escalated like before. ctx bounds the whole phase: if it's cancelled or
+// expires first, every still-draining process is treated the same as one
+// that ran out its own grace period.
+func runDrainPhase(ctx context.Context) {
+	processMutex.RLock()
+	procs := make(map[string]*exec.Cmd, len(runningProcesses))
+	for name, cmd := range runningProcesses {
+		procs[name] = cmd
+	}
+	sessions := make(map[string]*ssh.Session, len(runningSessions))
+	for name, session := range runningSessions {
+		sessions[name] = session
+	}
+	processMutex.RUnlock()
+
+	if len(procs) == 0 && len(sessions) == 0 {
+		return
+	}
+
+	resetDrained()
+	fmt.Println("Entering drain phase...")
+
+	var wg sync.WaitGroup
+
+	for name, cmd := range procs {
+		if cmd.Process == nil {
+			continue
+		}
+		sig, ok := drainSignal(name)
+		if !ok {
+			continue
+		}
+
+		pgid, err := syscall.Getpgid(cmd.Process.Pid)
+		if err != nil {
+			pgid = cmd.Process.Pid
+		}
+		if err := syscall.Kill(-pgid, sig.os); err != nil {
+			fmt.Printf("[%s] Failed to send drain signal: %v\n", name, err)
+			continue
+		}
+
+		beginDrainWait(ctx, name, &wg)
+	}
+
+	for name := range sessions {
+		sig, ok := drainSignal(name)
+		if !ok {
+			continue
+		}
+
+		spec, ok := getProcessSpec(name)
+		if !ok {
+			fmt.Printf("[%s] No recorded launch spec, skipping drain signal\n", name)
+			continue
+		}
+		if err := signalRemoteProcessGroup(spec.serverHost, name, sig.ssh); err != nil {
+			fmt.Printf("[%s] Failed to send drain signal: %v\n", name, err)
+			continue
+		}
+
+		beginDrainWait(ctx, name, &wg)
+	}
+
+	wg.Wait()
+	fmt.Println("Drain phase complete.")
+}
+
+func drainSignal(name string) (struct {
+	os  syscall.Signal
+	ssh ssh.Signal
+}, bool) {
+	signalName := commandConfigFor(name).DrainSignal
+	if signalName == "" {
+		signalName = defaultDrainSignal
+	}
+	sig, ok := drainSignals[signalName]
+	if !ok {
+		fmt.Printf("[%s] Unknown drainSignal %q, skipping drain\n", name, signalName)
+	}
+	return sig, ok
+}
+
+// beginDrainWait marks name as "draining" and kicks off waitForDrain on wg,
+// which the caller is expected to wg.Wait() on once every running process
+// has been signaled.
+func beginDrainWait(ctx context.Context, name string, wg *sync.WaitGroup) {
+	updateProcessState(name, "status", "draining", nil)
+	broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"draining"}`, name))
+
+	wg.Add(1)
+	go waitForDrain(ctx, name, wg)
+}
+
+// waitForDrain polls isDrained(name) until it's true, the process's
+// GracePeriodSeconds elapses, or ctx is done, whichever comes first.
+func waitForDrain(ctx context.Context, name string, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	graceSeconds := commandConfigFor(name).GracePeriodSeconds
+	if graceSeconds <= 0 {
+		graceSeconds = defaultGracePeriodSeconds
+	}
+	deadline := time.Now().Add(time.Duration(graceSeconds) * time.Second)
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if isDrained(name) {
+			updateProcessState(name, "status", "drained", nil)
+			broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"drained"}`, name))
+			return
+		}
+		if time.Now().After(deadline) {
+			fmt.Printf("[%s] Grace period elapsed before drain was reported, escalating\n", name)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			fmt.Printf("[%s] Shutdown deadline reached before drain was reported, escalating\n", name)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleShutdownHTTP implements POST /shutdown?drain=true, letting an
+// operator trigger the same graceful shutdown sequence Ctrl+C does without
+// needing a terminal attached to the watcher's process.
+func handleShutdownHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if activeLauncher == nil {
+		http.Error(w, "launcher is not running", http.StatusServiceUnavailable)
+		return
+	}
+	if atomic.LoadInt32(&shuttingDown) != 0 {
+		http.Error(w, "shutdown already in progress", http.StatusConflict)
+		return
+	}
+
+	drain, _ := strconv.ParseBool(r.URL.Query().Get("drain"))
+
+	fmt.Printf("Received POST /shutdown (drain=%v)\n", drain)
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintln(w, "shutdown initiated")
+
+	go func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		activeLauncher.Stop(stopCtx, drain)
+	}()
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	httpShutdownTimeout = 5 * time.Second
+	processDrainTimeout = 15 * time.Second
+)
+
+// Launcher owns this process's HTTP server and start/stop lifecycle. The
+// process table, SSH pool, and websocket hub it drives are still
+// package-level state shared by every file in this package - genuinely
+// moving those into Launcher fields would mean threading a receiver
+// through the whole package - so only one Launcher is meant to be live at a
+// time per process (main constructs exactly one). What this buys over the
+// old monolithic main is that Start/Stop are ordinary methods with a return
+// value, and every shutdown path (signal handler, POST /shutdown, a future
+// caller) now goes through the same sync.Once-guarded Stop. A listener error
+// from the HTTP server after Start has returned still ends the process via
+// log.Fatal in its serving goroutine.
+type Launcher struct {
+	httpServer *http.Server
+
+	stopOnce sync.Once
+	doneCh   chan struct{}
+	stopErr  error
+}
+
+// activeLauncher is the Launcher started by main, so package-level HTTP
+// handlers registered before it exists (POST /shutdown) have something to
+// call Stop on.
+var activeLauncher *Launcher
+
+// NewLauncher loads configuration (see initConfig) and returns a Launcher
+// ready for Start.
+func NewLauncher() (*Launcher, error) {
+	if err := initConfig(); err != nil {
+		return nil, err
+	}
+	return &Launcher{doneCh: make(chan struct{})}, nil
+}
+
+// Start connects configured SSH servers, wires up the HTTP routes, and
+// launches the coordinator/client startup sequence and the SIGHUP reload
+// watchers in the background. It returns as soon as the HTTP server's
+// ListenAndServe goroutine is launched, without waiting for it to be
+// listening; callers wait for shutdown via Done(), not Start blocking.
+func (l *Launcher) Start(ctx context.Context) error {
+	cfg := getConfig()
+	for i := range cfg.Servers {
+		if cfg.Servers[i].Host != "" && cfg.Servers[i].Host != "localhost" {
+			if err := ensureSSHConnected(cfg.Servers[i].Host); err != nil {
+				return fmt.Errorf("connect to SSH server %s: %w", cfg.Servers[i].Host, err)
+			}
+			fmt.Printf("Connected to SSH server: %s\n", cfg.Servers[i].Host)
+		}
+	}
+
+	http.HandleFunc("/ws", handleWebSocket)
+	http.HandleFunc("/logs/", handleLogsHTTP)
+	http.HandleFunc("/shutdown", handleShutdownHTTP)
+	http.HandleFunc("/clients/", handleClientActionHTTP)
+	http.HandleFunc("/rolling-restart", handleRollingRestartHTTP)
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "index.html")
+	})
+
+	runCtx, runCancel = context.WithCancel(ctx)
+	go runStartupSequence(runCtx)
+
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	startReloadWatchers(hupChan)
+
+	fmt.Printf("Server running on http://localhost:%d with %d total clients across %d servers\n", cfg.Port, getTotalClientCount(), len(cfg.Servers))
+	for _, server := range cfg.Servers {
+		if server.Host == "" || server.Host == "localhost" {
+			fmt.Printf("  Local: %d clients\n", server.ClientCount)
+		} else {
+			fmt.Printf("  %s: %d clients\n", server.Host, server.ClientCount)
+		}
+	}
+
+	l.httpServer = &http.Server{Addr: fmt.Sprintf(":%d", cfg.Port)}
+	go func() {
+		if err := l.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	return nil
+}
+
+// Done returns a channel that's closed once Stop has finished running.
+func (l *Launcher) Done() <-chan struct{} {
+	return l.doneCh
+}
+
+// StopErr returns the error Stop finished with, or nil if Stop hasn't run
+// (or completed cleanly). Only meaningful after <-l.Done().
+func (l *Launcher) StopErr() error {
+	return l.stopErr
+}
+
+// Stop runs the shutdown sequence exactly once, guarded by a sync.Once:
+// stop accepting new HTTP/WS work, optionally drain running processes,
+// escalate through SIGTERM -> processDrainTimeout -> SIGKILL, close the SSH
+// pool, then wait for output goroutines to finish draining. It's safe to
+// call concurrently from the signal handler, POST /shutdown, and a future
+// programmatic caller - only the first call does any work; the rest block
+// until it's done and observe the same result via Done()/StopErr().
+func (l *Launcher) Stop(ctx context.Context, drain bool) error {
+	l.stopOnce.Do(func() {
+		l.stopErr = l.stop(ctx, drain)
+		close(l.doneCh)
+	})
+	return l.stopErr
+}
+
+func (l *Launcher) stop(ctx context.Context, drain bool) error {
+	// Stop accepting new websocket upgrades and HTTP requests immediately;
+	// in-flight ones still get to finish below.
+	atomic.StoreInt32(&shuttingDown, 1)
+
+	// Unblock every wait loop still selecting on runCtx.Done() (the
+	// coordinator readiness wait, each client's metrics checker) so they
+	// return immediately instead of running out their current timeout.
+	runCancel()
+
+	if l.httpServer != nil {
+		shutdownCtx, shutdownCancel := context.WithTimeout(ctx, httpShutdownTimeout)
+		if err := l.httpServer.Shutdown(shutdownCtx); err != nil {
+			fmt.Printf("Error shutting down HTTP server: %v\n", err)
+		}
+		shutdownCancel()
+	}
+
+	if drain {
+		runDrainPhase(ctx)
+	}
+
+	// Snapshot what killAllProcesses is about to clear, so a straggler
+	// that ignores SIGTERM can still be force-killed below.
+	processMutex.Lock()
+	strayProcesses := make(map[string]*exec.Cmd, len(runningProcesses))
+	for name, cmd := range runningProcesses {
+		strayProcesses[name] = cmd
+	}
+	straySessions := make(map[string]*ssh.Session, len(runningSessions))
+	for name, session := range runningSessions {
+		straySessions[name] = session
+	}
+	processMutex.Unlock()
+
+	// Use the comprehensive killAllProcesses function
+	killAllProcesses()
+
+	// Close SSH connections
+	for _, server := range getConfig().Servers {
+		if server.Host != "" && server.Host != "localhost" {
+			fmt.Printf("Closing SSH connection to %s...\n", server.Host)
+			closeSSHPool(server.Host)
+		}
+	}
+
+	// outputWG only completes once every process's stdout/stderr pipe has
+	// hit EOF, i.e. the process has actually exited, not just been signaled.
+	outputDrained := make(chan struct{})
+	go func() {
+		outputWG.Wait()
+		close(outputDrained)
+	}()
+
+	stragglerCtx, stragglerCancel := context.WithTimeout(ctx, processDrainTimeout)
+	defer stragglerCancel()
+
+	select {
+	case <-outputDrained:
+		fmt.Println("Graceful shutdown complete.")
+		return nil
+	case <-stragglerCtx.Done():
+		fmt.Println("Cleanup timeout reached. Force killing stragglers.")
+		for name, cmd := range strayProcesses {
+			if cmd.Process == nil {
+				continue
+			}
+			if pgid, err := syscall.Getpgid(cmd.Process.Pid); err == nil {
+				syscall.Kill(-pgid, syscall.SIGKILL)
+			} else {
+				cmd.Process.Kill()
+			}
+			fmt.Printf("Force killed straggling process %s\n", name)
+		}
+		for name, session := range straySessions {
+			session.Close()
+			fmt.Printf("Force closed straggling SSH session %s\n", name)
+		}
+		return fmt.Errorf("cleanup timed out after %s, force killed stragglers", processDrainTimeout)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// preStopHookTimeout bounds how long a single preStop hook (exec or
+// httpGet) is allowed to run, independent of and prior to the process's own
+// TerminationGracePeriodSeconds wait for SIGTERM to take effect. A hook that
+// hangs past this is abandoned so shutdown/restart can still proceed.
+const preStopHookTimeout = 30 * time.Second
+
+// terminationGrace returns how long stopProcessGracefully waits after
+// SIGTERM (and after any preStop hook returns) before escalating to
+// SIGKILL, defaulting to defaultRestartGrace when unset.
+func terminationGrace(cmdCfg CommandConfig) time.Duration {
+	if cmdCfg.TerminationGracePeriodSeconds <= 0 {
+		return defaultRestartGrace
+	}
+	return time.Duration(cmdCfg.TerminationGracePeriodSeconds) * time.Second
+}
+
+// runPreStopHook runs name's configured preStop hook, if any, blocking until
+// it completes or preStopHookTimeout elapses. Errors are logged, not
+// returned: a failing or slow hook should delay the kill, not abort it.
+func runPreStopHook(name string, cmdCfg CommandConfig) {
+	if cmdCfg.PreStop == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), preStopHookTimeout)
+	defer cancel()
+
+	switch {
+	case cmdCfg.PreStop.Exec != nil:
+		runPreStopExec(ctx, name, cmdCfg.PreStop.Exec)
+	case cmdCfg.PreStop.HTTPGet != nil:
+		runPreStopHTTPGet(ctx, name, cmdCfg.PreStop.HTTPGet)
+	default:
+		fmt.Printf("[%s] preStop configured with neither exec nor httpGet, skipping\n", name)
+	}
+}
+
+// runPreStopExec runs hook's command to completion, locally or over SSH on
+// name's server depending on how it was launched.
+func runPreStopExec(ctx context.Context, name string, hook *ExecHook) {
+	spec, ok := getProcessSpec(name)
+
+	if !ok || !spec.useSSH {
+		cmd := exec.CommandContext(ctx, hook.Command, hook.Args...)
+		if ok && spec.workingDir != "" {
+			cmd.Dir = spec.workingDir
+		}
+		if out, err := cmd.CombinedOutput(); err != nil {
+			fmt.Printf("[%s] preStop exec failed: %v\noutput: %s\n", name, err, out)
+		} else {
+			fmt.Printf("[%s] preStop exec completed\n", name)
+		}
+		return
+	}
+
+	session, err := GetSession(spec.serverHost)
+	if err != nil {
+		fmt.Printf("[%s] preStop exec: failed to open SSH session on %s: %v\n", name, spec.serverHost, err)
+		return
+	}
+	defer session.Close()
+
+	command := hook.Command
+	for _, arg := range hook.Args {
+		command += " " + arg
+	}
+	if spec.workingDir != "" {
+		command = fmt.Sprintf("cd %s && %s", spec.workingDir, command)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- session.Run(command) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			fmt.Printf("[%s] preStop exec over SSH failed: %v\n", name, err)
+		} else {
+			fmt.Printf("[%s] preStop exec over SSH completed\n", name)
+		}
+	case <-ctx.Done():
+		fmt.Printf("[%s] preStop exec over SSH timed out after %s\n", name, preStopHookTimeout)
+		session.Signal(ssh.SIGKILL)
+	}
+}
+
+// runPreStopHTTPGet sends hook's request and waits for a response, logging
+// (but not acting on) a non-2xx status - a hook that returns an error is
+// still a hook that returned.
+func runPreStopHTTPGet(ctx context.Context, name string, hook *HTTPGetHook) {
+	method := hook.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, hook.URL, nil)
+	if err != nil {
+		fmt.Printf("[%s] preStop httpGet: bad request: %v\n", name, err)
+		return
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Printf("[%s] preStop httpGet failed: %v\n", name, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	fmt.Printf("[%s] preStop httpGet completed with status %s\n", name, resp.Status)
+}
+
+// stopProcessGracefully is the shared teardown for one managed process,
+// used by both killProcess and killAllProcesses: run its preStop hook (if
+// any), send SIGTERM, wait up to its termination grace period for it to
+// exit, then SIGKILL. Unlike the drain phase, this doesn't wait on the
+// process to self-report - it's the unconditional kill path drain falls
+// back to when a process didn't drain in time, or was never drain-capable.
+func stopProcessGracefully(name string) {
+	processMutex.RLock()
+	cmd, isLocal := runningProcesses[name]
+	session, isSSH := runningSessions[name]
+	processMutex.RUnlock()
+
+	if !isLocal && !isSSH {
+		return
+	}
+
+	updateProcessState(name, "status", "killing", nil)
+	broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"killing"}`, name))
+
+	cmdCfg := commandConfigFor(name)
+	runPreStopHook(name, cmdCfg)
+
+	switch {
+	case isLocal:
+		if cmd.Process == nil {
+			break
+		}
+		if err := signalLocalProcessGroup(cmd, syscall.SIGTERM); err != nil {
+			fmt.Printf("[%s] Error sending SIGTERM, escalating straight to SIGKILL: %v\n", name, err)
+			if err := signalLocalProcessGroup(cmd, syscall.SIGKILL); err != nil {
+				fmt.Printf("[%s] Error sending SIGKILL: %v\n", name, err)
+			}
+		}
+	case isSSH:
+		spec, ok := getProcessSpec(name)
+		if !ok {
+			fmt.Printf("[%s] No recorded launch spec, closing session instead of signaling\n", name)
+			session.Close()
+			break
+		}
+		if err := signalRemoteProcessGroup(spec.serverHost, name, ssh.SIGTERM); err != nil {
+			fmt.Printf("[%s] Error forwarding SIGTERM over SSH, closing session: %v\n", name, err)
+			session.Close()
+		}
+	}
+
+	waitForProcessExit(name, terminationGrace(cmdCfg))
+
+	updateProcessState(name, "status", "finalized", nil)
+	broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"finalized"}`, name))
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// logger is the structured, level-filtered logger for pswatcher's own
+// operational messages. Per-process output still flows through
+// updateProcessState/broadcast and the rotating per-process log files below.
+var logger = logrus.New()
+
+// configureLogger sets the minimum level logged, parsed from config.LogLevel
+// ("debug", "info", "warn", "error", ...); an unrecognized level falls back
+// to info rather than failing startup.
+func configureLogger(level string) {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		parsed = logrus.InfoLevel
+	}
+	logger.SetLevel(parsed)
+}
+
+// ProcessLogger is an append-only log file for a single named process, in
+// the style of a lumberjack sink: it is rotated by size, and rotated backups
+// are pruned by age and count.
+type ProcessLogger struct {
+	mu         sync.Mutex
+	path       string
+	file       *os.File
+	size       int64
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+}
+
+var (
+	processLoggersMu sync.Mutex
+	processLoggers   = make(map[string]*ProcessLogger)
+)
+
+// getProcessLogger returns the rotating log file for name, opening it
+// (lazily, once per process) under config.LogDir.
+func getProcessLogger(name string) *ProcessLogger {
+	processLoggersMu.Lock()
+	defer processLoggersMu.Unlock()
+
+	if pl, ok := processLoggers[name]; ok {
+		return pl
+	}
+
+	cfg := getConfig()
+	pl, err := newProcessLogger(cfg.LogDir, sanitizeProcessName(name), cfg.LogMaxSizeMB, cfg.LogMaxAgeDays, cfg.LogMaxBackups)
+	if err != nil {
+		logger.Errorf("failed to open log file for %s: %v", name, err)
+		return nil
+	}
+	processLoggers[name] = pl
+	return pl
+}
+
+func newProcessLogger(dir, name string, maxSizeMB, maxAgeDays, maxBackups int) (*ProcessLogger, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log dir %s: %w", dir, err)
+	}
+
+	pl := &ProcessLogger{
+		path:       filepath.Join(dir, name+".log"),
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxAge:     time.Duration(maxAgeDays) * 24 * time.Hour,
+		maxBackups: maxBackups,
+	}
+	if err := pl.openFile(); err != nil {
+		return nil, err
+	}
+	return pl, nil
+}
+
+func (pl *ProcessLogger) openFile() error {
+	f, err := os.OpenFile(pl.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file %s: %w", pl.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	pl.file = f
+	pl.size = info.Size()
+	return nil
+}
+
+// WriteLine appends a single RFC3339Nano-timestamped line, rotating first
+// if the write would push the file past maxSize.
+func (pl *ProcessLogger) WriteLine(line string) error {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	entry := fmt.Sprintf("%s %s\n", time.Now().UTC().Format(time.RFC3339Nano), line)
+
+	if pl.maxSize > 0 && pl.size+int64(len(entry)) > pl.maxSize {
+		if err := pl.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := pl.file.WriteString(entry)
+	pl.size += int64(n)
+	return err
+}
+
+// rotate renames the current log file aside with a timestamp suffix, opens
+// a fresh one in its place, and prunes backups past maxAge/maxBackups.
+func (pl *ProcessLogger) rotate() error {
+	pl.file.Close()
+
+	rotated := fmt.Sprintf("%s.%s", pl.path, time.Now().UTC().Format("20060102T150405.000000000"))
+	if err := os.Rename(pl.path, rotated); err != nil {
+		return fmt.Errorf("failed to rotate log file %s: %w", pl.path, err)
+	}
+
+	pl.pruneBackups()
+	return pl.openFile()
+}
+
+// pruneBackups removes rotated backups older than maxAge, then trims down
+// to maxBackups if there are still too many.
+func (pl *ProcessLogger) pruneBackups() {
+	dir := filepath.Dir(pl.path)
+	base := filepath.Base(pl.path)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	var backups []string
+	cutoff := time.Now().Add(-pl.maxAge)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), base+".") {
+			continue
+		}
+		if pl.maxAge > 0 {
+			info, err := e.Info()
+			if err == nil && info.ModTime().Before(cutoff) {
+				os.Remove(filepath.Join(dir, e.Name()))
+				continue
+			}
+		}
+		backups = append(backups, e.Name())
+	}
+
+	if pl.maxBackups > 0 && len(backups) > pl.maxBackups {
+		// Timestamp-suffixed names sort chronologically.
+		sort.Strings(backups)
+		for _, name := range backups[:len(backups)-pl.maxBackups] {
+			os.Remove(filepath.Join(dir, name))
+		}
+	}
+}
+
+// sanitizeProcessName maps name onto [A-Za-z0-9_-], replacing every other
+// character (such as the dots of a hostname in "<server>-clientN") with '_',
+// so a path segment like "../../etc/passwd" can't be used to escape LogDir in
+// readProcessLog. getProcessLogger names the file it writes the same way, so
+// both resolve to the same file. Same allowlist approach as
+// remoteControlPath in remotecontrol.go.
+func sanitizeProcessName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// readProcessLog returns a process's persisted log lines, optionally
+// filtered to those at or after since and/or limited to the last tail
+// lines. A missing log file (nothing written yet) is not an error.
+func readProcessLog(name string, since time.Time, tail int) ([]string, error) {
+	path := filepath.Join(getConfig().LogDir, sanitizeProcessName(name)+".log")
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open log file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if !since.IsZero() {
+			if ts, _, ok := strings.Cut(line, " "); ok {
+				if parsed, err := time.Parse(time.RFC3339Nano, ts); err == nil && parsed.Before(since) {
+					continue
+				}
+			}
+		}
+
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read log file %s: %w", path, err)
+	}
+
+	if tail > 0 && len(lines) > tail {
+		lines = lines[len(lines)-tail:]
+	}
+
+	return lines, nil
+}
+
+// handleLogsHTTP serves GET /logs/{name}?since=<RFC3339Nano>&tail=<N>,
+// letting the UI page through a process's persisted history instead of
+// only the in-memory tail kept by updateProcessState.
+func handleLogsHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/logs/")
+	if name == "" {
+		http.Error(w, "missing process name", http.StatusBadRequest)
+		return
+	}
+
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid since: %v", err), http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	tail := 0
+	if t := r.URL.Query().Get("tail"); t != "" {
+		n, err := strconv.Atoi(t)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid tail: %v", err), http.StatusBadRequest)
+			return
+		}
+		tail = n
+	}
+
+	lines, err := readProcessLog(name, since, tail)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read logs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"name": name, "lines": lines})
+}
+
+// handleReplayCommand answers a websocket `{"type":"replay","name":...}`
+// command with the matching persisted history, so the UI can page through
+// it the same way handleLogsHTTP does over plain HTTP.
+func handleReplayCommand(conn *websocket.Conn, cmd map[string]any) {
+	name, _ := cmd["name"].(string)
+	if name == "" {
+		return
+	}
+
+	tail := 0
+	if t, ok := cmd["tail"].(float64); ok {
+		tail = int(t)
+	}
+
+	var since time.Time
+	if s, ok := cmd["since"].(string); ok && s != "" {
+		if parsed, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			since = parsed
+		}
+	}
+
+	lines, err := readProcessLog(name, since, tail)
+	if err != nil {
+		logger.Errorf("replay: failed to read logs for %s: %v", name, err)
+		return
+	}
+
+	replayJSON, err := json.Marshal(map[string]any{
+		"type":  "replay",
+		"name":  name,
+		"lines": lines,
+	})
+	if err != nil {
+		return
+	}
+
+	connMutex := connMutexes[conn]
+	if connMutex == nil {
+		return
+	}
+	connMutex.Lock()
+	conn.WriteMessage(websocket.TextMessage, replayJSON)
+	connMutex.Unlock()
+}
@@ -2,11 +2,11 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
-	"net"
 	"net/http"
 	"os"
 	"os/exec"
@@ -14,11 +14,15 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/ssh"
+
+	"github.com/PsycheFoundation/psyche/tools/go-tools/internal/timerpool"
 )
 
 var upgrader = websocket.Upgrader{
@@ -26,27 +30,116 @@ var upgrader = websocket.Upgrader{
 }
 
 type ServerConfig struct {
-	Host             string      `json:"host"`
-	ClientCount      int         `json:"clientCount"`
-	WorkingDirectory string      `json:"workingDirectory"`
-	MetricsStartPort int         `json:"metricsStartPort"`
-	SSHClient        *ssh.Client `json:"-"`
+	Host             string `json:"host"`
+	ClientCount      int    `json:"clientCount"`
+	WorkingDirectory string `json:"workingDirectory"`
+	MetricsStartPort int    `json:"metricsStartPort"`
+	// InsecureIgnoreHostKey skips known_hosts verification for this server.
+	// Off by default; only meant for throwaway/local test servers.
+	InsecureIgnoreHostKey bool `json:"insecureIgnoreHostKey"`
+	// Env is a list of "KEY=VALUE" pairs exported before the remote command
+	// runs, in addition to whatever the command itself sets.
+	Env []string `json:"env"`
+	// PathPrepend is prepended to the remote shell's PATH, replacing the
+	// operator-specific hardcoded PATH this used to ship with.
+	PathPrepend string `json:"pathPrepend"`
 }
 
 type CommandConfig struct {
 	Command string   `json:"command"`
 	Args    []string `json:"args"`
+	// Autorestart controls restart behavior on exit: "never" (default),
+	// "on-failure" (restart only on a non-zero exit), or "always".
+	Autorestart string `json:"autorestart"`
+	// StartSeconds is the minimum uptime before an exit is treated as a
+	// successful run rather than a fast-failing start attempt.
+	StartSeconds int `json:"startSeconds"`
+	// StartRetries bounds consecutive fast-fail restarts before the
+	// process is marked "fatal" and supervision gives up.
+	StartRetries int `json:"startRetries"`
+	// DrainSignal is the signal sent to let this command finish in-flight
+	// work (e.g. a training step, flushing metrics) before a graceful
+	// shutdown escalates to SIGTERM/SIGKILL. Defaults to "SIGUSR1"; must be
+	// one of the names in drainSignals.
+	DrainSignal string `json:"drainSignal"`
+	// GracePeriodSeconds bounds how long a graceful shutdown waits for this
+	// command to report itself drained (via a "drained" WS message) before
+	// giving up and escalating anyway. Defaults to 30.
+	GracePeriodSeconds int `json:"gracePeriodSeconds"`
+	// PreStop, if set, runs before this command is sent SIGTERM on kill or
+	// restart, mirroring Kubernetes' pod preStop lifecycle hook -- e.g. the
+	// coordinator checkpointing and de-registering from a run before it dies.
+	PreStop *PreStopConfig `json:"preStop"`
+	// TerminationGracePeriodSeconds bounds how long a kill or restart waits
+	// after SIGTERM (and after PreStop returns) before escalating to SIGKILL,
+	// mirroring the Kubernetes pod field of the same name. Defaults to 30.
+	TerminationGracePeriodSeconds int `json:"terminationGracePeriodSeconds"`
+}
+
+// PreStopConfig runs one hook, exactly one of Exec or HTTPGet, before a
+// managed process is sent SIGTERM.
+type PreStopConfig struct {
+	// Exec runs Command+Args locally, or over SSH on the process's server.
+	Exec *ExecHook `json:"exec"`
+	// HTTPGet sends an HTTP request to URL instead of running a command.
+	HTTPGet *HTTPGetHook `json:"httpGet"`
+}
+
+// ExecHook is a preStop.exec hook: a command run to completion (or until
+// preStopHookTimeout) before the process it belongs to is signaled.
+type ExecHook struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+}
+
+// HTTPGetHook is a preStop.httpGet hook.
+type HTTPGetHook struct {
+	URL string `json:"url"`
+	// Method defaults to GET.
+	Method string `json:"method"`
+}
+
+// MetricsConfig controls how client metrics are scraped from each training
+// client's Prometheus "/metrics" endpoint.
+type MetricsConfig struct {
+	// Path is the HTTP path to scrape, e.g. "/metrics".
+	Path string `json:"path"`
+	// BearerToken, if set, is sent as "Authorization: Bearer <token>".
+	BearerToken string `json:"bearerToken"`
+	// TLS scrapes over https instead of http.
+	TLS bool `json:"tls"`
+	// InsecureSkipVerify disables certificate verification when TLS is set.
+	InsecureSkipVerify bool `json:"insecureSkipVerify"`
+	// Rename maps an exposed metric name to the name sent to the dashboard.
+	Rename map[string]string `json:"rename"`
+	// Allowlist, if non-empty, restricts scraped series to these metric
+	// names (post-rename names are not matched against it).
+	Allowlist []string `json:"allowlist"`
 }
 
 type Config struct {
 	Port            int                      `json:"port"`
-	Verbose         bool                     `json:"verbose"`
+	LogLevel        string                   `json:"logLevel"`
 	CoordinatorHost string                   `json:"coordinatorHost"`
 	CheckInterval   string                   `json:"checkInterval"`
 	Servers         []ServerConfig           `json:"servers"`
 	Commands        map[string]CommandConfig `json:"commands"`
+	Metrics         MetricsConfig            `json:"metrics"`
+	// LogDir is where per-process append-only log files are written.
+	LogDir string `json:"logDir"`
+	// LogMaxSizeMB/LogMaxAgeDays/LogMaxBackups bound a process log file's
+	// on-disk footprint: it is rotated once it exceeds LogMaxSizeMB, and
+	// rotated backups older than LogMaxAgeDays or beyond LogMaxBackups are
+	// pruned.
+	LogMaxSizeMB  int `json:"logMaxSizeMB"`
+	LogMaxAgeDays int `json:"logMaxAgeDays"`
+	LogMaxBackups int `json:"logMaxBackups"`
 }
 
+// coordinatorReadyTimeout bounds how long runStartupSequence waits for the
+// coordinator's ready signal before starting clients anyway.
+const coordinatorReadyTimeout = 300 * time.Second
+
 var clients = make(map[*websocket.Conn]bool)
 var coordinatorReady = make(chan bool)
 var processStates = make(map[string]map[string]any)
@@ -57,9 +150,84 @@ var processMutex sync.RWMutex
 var clientsMutex sync.RWMutex
 var connMutexes = make(map[*websocket.Conn]*sync.Mutex)
 var clientPorts = make(map[string]int)
+
+// configMu guards config and checkInterval, which reloadConfig replaces
+// wholesale while dozens of other goroutines (metrics loops, HTTP handlers,
+// client startup) read them concurrently. Use getConfig/setConfig and
+// getCheckInterval/setCheckInterval instead of touching the vars directly.
+var configMu sync.RWMutex
 var config Config
 var totalClientCount int
 var checkInterval time.Duration
+var configPath string
+var configFileModTime time.Time
+
+// getConfig returns the currently active configuration. Callers get a
+// snapshot: later reloads don't mutate the Config a caller already holds,
+// they swap in a new one.
+func getConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
+
+// setConfig installs c as the active configuration.
+func setConfig(c Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = c
+}
+
+// getCheckInterval returns the interval metrics loops currently poll at.
+func getCheckInterval() time.Duration {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return checkInterval
+}
+
+// setCheckInterval changes the interval metrics loops poll at; they read it
+// on every iteration via getCheckInterval, so no restart is needed.
+func setCheckInterval(d time.Duration) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	checkInterval = d
+}
+
+// getTotalClientCount returns the client count last computed by
+// setTotalClientCount, for the WebSocket broadcast in handleWebSocket.
+func getTotalClientCount() int {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return totalClientCount
+}
+
+// setTotalClientCount updates the client count reloadConfig and initConfig
+// recompute from the active config.
+func setTotalClientCount(n int) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	totalClientCount = n
+}
+
+// outputWG tracks every in-flight handleProcessOutput goroutine, so a
+// graceful shutdown can wait for them to drain instead of tearing down the
+// process mid-read.
+var outputWG sync.WaitGroup
+
+// shuttingDown is set once a graceful shutdown has started, so
+// handleWebSocket can refuse new upgrades instead of accepting connections
+// that are about to be severed.
+var shuttingDown int32
+
+// runCtx is cancelled by Launcher.Stop, so every long-running wait loop
+// started under it (the coordinator readiness wait, each client's metrics
+// checker) unblocks immediately at shutdown instead of running out its
+// current timeout first. It's reassigned by Launcher.Start; the zero value
+// lets code that reads it before Start (there is none today) not crash.
+var (
+	runCtx    context.Context = context.Background()
+	runCancel context.CancelFunc = func() {}
+)
 
 // Regular expression to match ANSI escape codes
 var ansiRegex = regexp.MustCompile(`\x1b\[[0-9;]*m`)
@@ -69,9 +237,13 @@ func loadConfig(filename string) (Config, error) {
 
 	// Set defaults
 	cfg.Port = 8888
-	cfg.Verbose = false
+	cfg.LogLevel = "info"
 	cfg.CoordinatorHost = "localhost"
 	cfg.CheckInterval = "5s"
+	cfg.LogDir = "logs"
+	cfg.LogMaxSizeMB = 10
+	cfg.LogMaxAgeDays = 7
+	cfg.LogMaxBackups = 5
 	cfg.Servers = []ServerConfig{
 		{
 			Host:             "localhost",
@@ -81,10 +253,11 @@ func loadConfig(filename string) (Config, error) {
 		},
 	}
 	cfg.Commands = map[string]CommandConfig{
-		"coordinator": {Command: "just", Args: []string{"setup-solana-localnet-dummy-test-run"}},
-		"client":      {Command: "just", Args: []string{"start-training-localnet-light-client"}},
+		"coordinator": {Command: "just", Args: []string{"setup-solana-localnet-dummy-test-run"}, Autorestart: "on-failure", StartSeconds: 10, StartRetries: 3},
+		"client":      {Command: "just", Args: []string{"start-training-localnet-light-client"}, Autorestart: "on-failure", StartSeconds: 10, StartRetries: 5},
 		"cleanup":     {Command: "killall", Args: []string{"solana-test-validator"}},
 	}
+	cfg.Metrics = MetricsConfig{Path: "/metrics"}
 
 	// If config file doesn't exist, return defaults
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
@@ -124,6 +297,8 @@ func stopMetricsChecker(name string) {
 }
 
 func handleProcessOutput(name string, output io.Reader, isStderr bool, isCoordinator bool) {
+	defer outputWG.Done()
+
 	scanner := bufio.NewScanner(output)
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -131,11 +306,16 @@ func handleProcessOutput(name string, output io.Reader, isStderr bool, isCoordin
 		// Strip ANSI escape codes for clean web display
 		cleanLine := stripAnsiCodes(line)
 
-		// Print original line with colors to console only if verbose mode is enabled
-		if config.Verbose {
+		// Print original line with colors to console only at debug level
+		if logger.IsLevelEnabled(logrus.DebugLevel) {
 			fmt.Printf("[%s] %s\n", name, line)
 		}
 		updateProcessState(name, "output", "", cleanLine)
+		if pl := getProcessLogger(name); pl != nil {
+			if err := pl.WriteLine(cleanLine); err != nil {
+				logger.Warnf("failed to persist log line for %s: %v", name, err)
+			}
+		}
 
 		// Properly encode JSON to avoid control character issues
 		messageData := map[string]any{
@@ -259,7 +439,7 @@ func getMetricsHost(serverHost string) string {
 	return serverHost
 }
 
-func checkMetricsPort(name, serverHost string, port int) {
+func checkMetricsPort(ctx context.Context, name, serverHost string, port int) {
 	host := getMetricsHost(serverHost)
 	fmt.Printf("[%s] Starting metrics checker for %s:%d\n", name, host, port)
 
@@ -273,62 +453,61 @@ func checkMetricsPort(name, serverHost string, port int) {
 		case <-stopChan:
 			fmt.Printf("[%s] Stopping metrics checker\n", name)
 			return
+		case <-ctx.Done():
+			fmt.Printf("[%s] Stopping metrics checker (shutdown)\n", name)
+			return
 		default:
 		}
 
-		if !checkAndProcessMetrics(name, host, port, stopChan) {
+		if !checkAndProcessMetrics(ctx, name, host, port, stopChan) {
 			return
 		}
 	}
 }
 
-func checkAndProcessMetrics(name, host string, port int, stopChan chan bool) bool {
-	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", host, port), 2*time.Second)
+func checkAndProcessMetrics(ctx context.Context, name, host string, port int, stopChan chan bool) bool {
+	metricsCfg := getConfig().Metrics
+	client := newMetricsHTTPClient(metricsCfg)
+
+	samples, err := scrapeMetrics(client, host, port, metricsCfg)
 	if err != nil {
-		return waitOrStop(name, stopChan, checkInterval)
+		return waitOrStop(ctx, name, stopChan, getCheckInterval())
 	}
-	defer conn.Close()
 
-	buffer := make([]byte, 4096)
-	n, err := conn.Read(buffer)
-	if err != nil && err != io.EOF {
-		return waitOrStop(name, stopChan, checkInterval)
-	}
-
-	if n > 0 {
-		response := string(buffer[:n])
-		var metrics map[string]any
-		if err := json.Unmarshal([]byte(response), &metrics); err == nil {
-			// Check if this is the first time we're getting metrics - if so, change status to "running"
-			processMutex.RLock()
-			currentStatus := ""
-			if processStates[name] != nil {
-				currentStatus = processStates[name]["status"].(string)
-			}
-			processMutex.RUnlock()
-			
-			if currentStatus == "starting" {
-				updateProcessState(name, "status", "running", nil)
-				broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"running"}`, name))
-			}
-			
-			updateProcessState(name, "metrics", "", metrics)
-			metricsJSON, _ := json.Marshal(map[string]any{
-				"type": "metrics", "name": name, "metrics": metrics,
-			})
-			broadcast(string(metricsJSON))
-		}
+	// Check if this is the first time we're getting metrics - if so, change status to "running"
+	processMutex.RLock()
+	currentStatus := ""
+	if processStates[name] != nil {
+		currentStatus = processStates[name]["status"].(string)
 	}
+	processMutex.RUnlock()
+
+	if currentStatus == "starting" {
+		updateProcessState(name, "status", "running", nil)
+		broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"running"}`, name))
+	}
+
+	updateProcessState(name, "metrics", "", samples)
+	metricsJSON, _ := json.Marshal(map[string]any{
+		"type": "metrics", "name": name, "series": samples,
+	})
+	broadcast(string(metricsJSON))
 
-	return waitOrStop(name, stopChan, checkInterval)
+	return waitOrStop(ctx, name, stopChan, getCheckInterval())
 }
 
-func waitOrStop(name string, stopChan chan bool, duration time.Duration) bool {
+func waitOrStop(ctx context.Context, name string, stopChan chan bool, duration time.Duration) bool {
+	t := timerpool.Get(duration)
+	defer timerpool.Put(t)
+
 	select {
 	case <-stopChan:
 		fmt.Printf("[%s] Stopping metrics checker\n", name)
 		return false
-	case <-time.After(duration):
+	case <-ctx.Done():
+		fmt.Printf("[%s] Stopping metrics checker (shutdown)\n", name)
+		return false
+	case <-t.C:
 		return true
 	}
 }
@@ -344,7 +523,7 @@ func getServerName(host string) string {
 	return host
 }
 
-func handleWebSocketCommand(message []byte) {
+func handleWebSocketCommand(conn *websocket.Conn, message []byte) {
 	var cmd map[string]any
 	if err := json.Unmarshal(message, &cmd); err != nil {
 		fmt.Printf("Error parsing WebSocket message: %v\n", err)
@@ -362,54 +541,31 @@ func handleWebSocketCommand(message []byte) {
 	case "kill_all":
 		fmt.Printf("Attempting to kill all processes\n")
 		killAllProcesses()
+	case "replay":
+		handleReplayCommand(conn, cmd)
+	case "drained":
+		if processName, ok := cmd["name"].(string); ok {
+			fmt.Printf("Process reported itself drained: %s\n", processName)
+			markDrained(processName)
+		}
 	}
 }
 
-func connectSSH(serverHost string) (*ssh.Client, error) {
-	// Parse user@host format
-	user := os.Getenv("USER")
-	host := serverHost
-	if strings.Contains(serverHost, "@") {
-		parts := strings.SplitN(serverHost, "@", 2)
-		user = parts[0]
-		host = parts[1]
-	}
-
-	config := &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
-				homeDir := os.Getenv("HOME")
-				keyPath := homeDir + "/.ssh/id_rsa"
-				if _, err := os.Stat(keyPath); os.IsNotExist(err) {
-					keyPath = homeDir + "/.ssh/id_ed25519"
-				}
-				key, err := os.ReadFile(keyPath)
-				if err != nil {
-					return nil, err
-				}
-				signer, err := ssh.ParsePrivateKey(key)
-				if err != nil {
-					return nil, err
-				}
-				return []ssh.Signer{signer}, nil
-			}),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         30 * time.Second,
-	}
-
-	client, err := ssh.Dial("tcp", host+":22", config)
-	if err != nil {
-		return nil, err
+// runProcess starts a single run of a managed process (locally or over
+// SSH) and invokes onExit once it exits, with the error cmd.Wait()/
+// session.Wait() returned (nil for a clean exit). It does not retry —
+// startSupervised is what decides whether and when to call it again.
+func runProcess(ctx context.Context, name, command string, args []string, isCoordinator bool, env []string, useSSH bool, serverHost string, workingDir string, onExit func(err error)) {
+	if ctx.Err() != nil {
+		fmt.Printf("[%s] Not starting, shutdown already in progress: %v\n", name, ctx.Err())
+		if onExit != nil {
+			onExit(ctx.Err())
+		}
+		return
 	}
 
-	return client, nil
-}
-
-func runProcess(name, command string, args []string, isCoordinator bool, env []string, sshClient *ssh.Client, serverHost string, workingDir string) {
-	if sshClient != nil {
-		runSSHProcess(name, command, args, isCoordinator, env, sshClient, serverHost, workingDir)
+	if useSSH {
+		runSSHProcess(ctx, name, command, args, isCoordinator, env, serverHost, workingDir, onExit)
 		return
 	}
 
@@ -442,25 +598,31 @@ func runProcess(name, command string, args []string, isCoordinator bool, env []s
 		fmt.Printf("[%s] Failed to start: %v\n", name, err)
 		updateProcessState(name, "status", "failed", nil)
 		broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"failed"}`, name))
+		if onExit != nil {
+			onExit(err)
+		}
 		return
 	}
 
+	outputWG.Add(2)
 	go handleProcessOutput(name, stdout, false, isCoordinator)
-
 	go handleProcessOutput(name, stderr, true, isCoordinator)
 
 	go func() {
-		cmd.Wait()
+		err := cmd.Wait()
 		fmt.Printf("[%s] Process finished\n", name)
 		processMutex.Lock()
 		delete(runningProcesses, name)
 		processMutex.Unlock()
 		updateProcessState(name, "status", "finished", nil)
 		broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"finished"}`, name))
+		if onExit != nil {
+			onExit(err)
+		}
 	}()
 }
 
-func runSSHProcess(name, command string, args []string, isCoordinator bool, env []string, sshClient *ssh.Client, serverHost string, workingDir string) {
+func runSSHProcess(ctx context.Context, name, command string, args []string, isCoordinator bool, env []string, serverHost string, workingDir string, onExit func(err error)) {
 	updateProcessState(name, "status", "starting", nil)
 	broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"starting"}`, name))
 
@@ -479,23 +641,34 @@ func runSSHProcess(name, command string, args []string, isCoordinator bool, env
 	// Use the working directory from config
 	workDir := workingDir
 
-	// Extract username from serverHost for path construction
-	username := "admin" // default fallback
-	if strings.Contains(serverHost, "@") {
-		parts := strings.SplitN(serverHost, "@", 2)
-		username = parts[0]
+	// Exports come from this server's own config instead of a hardcoded
+	// operator home layout, so the same binary works across hosts.
+	var exports string
+	if serverCfg := findServerConfig(serverHost); serverCfg != nil {
+		if serverCfg.PathPrepend != "" {
+			exports += fmt.Sprintf("export PATH=\"%s:$PATH\" && ", serverCfg.PathPrepend)
+		}
+		for _, kv := range serverCfg.Env {
+			exports += fmt.Sprintf("export %s && ", kv)
+		}
 	}
 
-	// Change to the working directory and run the command with proper environment
-	fullCmd := fmt.Sprintf("export PATH=\"/home/%s/.local/share/solana/install/active_release/bin:/usr/local/cuda/bin:/home/%s/.local/bin:/home/%s/bin:/home/%s/.nvm/versions/node/v22.17.0/bin:/home/%s/.cargo/bin:$PATH\" && export LIBTORCH=$HOME/bin/libtorch && export LIBTORCH_INCLUDE=$LIBTORCH && export LIBTORCH_LIB=$LIBTORCH && export LD_LIBRARY_PATH=$LIBTORCH/lib:$LD_LIBRARY_PATH && cd %s && %s", username, username, username, username, username, workDir, cmdStr)
+	// Change to the working directory and run the command with proper
+	// environment, wrapped so it runs as the leader of its own process
+	// group with a control FIFO a later SIGTERM/SIGKILL can be forwarded
+	// through -- see wrapRemoteCommand.
+	fullCmd := fmt.Sprintf("%scd %s && %s", exports, workDir, wrapRemoteCommand(name, cmdStr))
 
 	fmt.Printf("[%s] Starting SSH process: %s\n", name, fullCmd)
 
-	session, err := sshClient.NewSession()
+	session, err := GetSession(serverHost)
 	if err != nil {
 		fmt.Printf("[%s] Failed to create SSH session: %v\n", name, err)
 		updateProcessState(name, "status", "failed", nil)
 		broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"failed"}`, name))
+		if onExit != nil {
+			onExit(err)
+		}
 		return
 	}
 
@@ -505,6 +678,9 @@ func runSSHProcess(name, command string, args []string, isCoordinator bool, env
 		session.Close()
 		updateProcessState(name, "status", "failed", nil)
 		broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"failed"}`, name))
+		if onExit != nil {
+			onExit(err)
+		}
 		return
 	}
 
@@ -514,6 +690,9 @@ func runSSHProcess(name, command string, args []string, isCoordinator bool, env
 		session.Close()
 		updateProcessState(name, "status", "failed", nil)
 		broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"failed"}`, name))
+		if onExit != nil {
+			onExit(err)
+		}
 		return
 	}
 
@@ -528,18 +707,21 @@ func runSSHProcess(name, command string, args []string, isCoordinator bool, env
 		session.Close()
 		updateProcessState(name, "status", "failed", nil)
 		broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"failed"}`, name))
+		if onExit != nil {
+			onExit(err)
+		}
 		return
 	}
 
+	outputWG.Add(2)
 	// Handle stdout
 	go handleProcessOutput(name, stdout, false, isCoordinator)
-
 	// Handle stderr
 	go handleProcessOutput(name, stderr, true, isCoordinator)
 
 	// Wait for the command to complete
 	go func() {
-		session.Wait()
+		waitErr := session.Wait()
 		session.Close()
 		fmt.Printf("[%s] SSH process finished\n", name)
 		processMutex.Lock()
@@ -547,10 +729,18 @@ func runSSHProcess(name, command string, args []string, isCoordinator bool, env
 		processMutex.Unlock()
 		updateProcessState(name, "status", "finished", nil)
 		broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"finished"}`, name))
+		if onExit != nil {
+			onExit(waitErr)
+		}
 	}()
 }
 
 func handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&shuttingDown) != 0 {
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return
@@ -574,8 +764,8 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Send initial configuration with client count and server information
 	configJSON, _ := json.Marshal(map[string]any{
 		"type":        "config",
-		"clientCount": totalClientCount,
-		"servers":     config.Servers,
+		"clientCount": getTotalClientCount(),
+		"servers":     getConfig().Servers,
 	})
 	connMutex.Lock()
 	conn.WriteMessage(websocket.TextMessage, configJSON)
@@ -612,99 +802,68 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 
-		handleWebSocketCommand(message)
-	}
-}
-
-func killLocalProcess(name string, cmd *exec.Cmd) {
-	fmt.Printf("Killing local process group for: %s (PID: %d)\n", name, cmd.Process.Pid)
-
-	// Kill the entire process group
-	pgid, err := syscall.Getpgid(cmd.Process.Pid)
-	if err != nil {
-		fmt.Printf("Error getting process group ID for %s: %v\n", name, err)
-		// Fallback to killing just the parent process
-		if err := cmd.Process.Kill(); err != nil {
-			fmt.Printf("Error killing process %s: %v\n", name, err)
-		}
-	} else {
-		fmt.Printf("Process group ID for %s: %d\n", name, pgid)
-		// Kill the entire process group
-		if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
-			fmt.Printf("Error killing process group %d: %v\n", pgid, err)
-			// Fallback to SIGKILL if SIGTERM fails
-			if err := syscall.Kill(-pgid, syscall.SIGKILL); err != nil {
-				fmt.Printf("Error force killing process group %d: %v\n", pgid, err)
-			}
-		} else {
-			fmt.Printf("Successfully sent SIGTERM to process group %d\n", pgid)
-		}
-	}
-
-	updateProcessState(name, "status", "finalized", nil)
-	broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"finalized"}`, name))
-}
-
-func killSSHSession(name string, session *ssh.Session) {
-	fmt.Printf("Killing SSH session for: %s\n", name)
-
-	// Send interrupt signal to the remote process
-	if err := session.Signal(ssh.SIGTERM); err != nil {
-		fmt.Printf("Error sending SIGTERM to SSH session %s: %v\n", name, err)
-		// Fallback to closing the session
-		session.Close()
-	} else {
-		fmt.Printf("Successfully sent SIGTERM to SSH session %s\n", name)
+		handleWebSocketCommand(conn, message)
 	}
-
-	updateProcessState(name, "status", "finalized", nil)
-	broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"finalized"}`, name))
 }
 
+// killProcess stops name: its preStop hook (if configured) runs first, then
+// SIGTERM, then up to its TerminationGracePeriodSeconds for it to exit, then
+// SIGKILL. The supervisor loop is told not to restart it.
 func killProcess(name string) {
-	// First update status to "killing"
-	updateProcessState(name, "status", "killing", nil)
-	broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"killing"}`, name))
-
-	processMutex.Lock()
-	defer processMutex.Unlock()
+	processMutex.RLock()
+	_, isLocal := runningProcesses[name]
+	_, isSSH := runningSessions[name]
+	processMutex.RUnlock()
 
-	// Check if it's a local process
-	if cmd, exists := runningProcesses[name]; exists {
-		killLocalProcess(name, cmd)
-		delete(runningProcesses, name)
-		stopMetricsChecker(name)
+	if !isLocal && !isSSH {
+		fmt.Printf("Process %s not found in running processes or sessions\n", name)
 		return
 	}
 
-	// Check if it's an SSH session
-	if session, exists := runningSessions[name]; exists {
-		killSSHSession(name, session)
-		delete(runningSessions, name)
-		stopMetricsChecker(name)
-		return
-	}
+	// Tell the supervisor loop not to restart this process once it exits.
+	markIntentionalStop(name)
 
-	fmt.Printf("Process %s not found in running processes or sessions\n", name)
-}
+	stopProcessGracefully(name)
 
-func killAllProcesses() {
 	processMutex.Lock()
-	defer processMutex.Unlock()
+	stopMetricsChecker(name)
+	processMutex.Unlock()
+}
 
+// killAllProcesses stops every running process/session the same way
+// killProcess does -- preStop hook, SIGTERM, grace period, SIGKILL -- each
+// one concurrently so this doesn't block for the sum of every process's
+// grace period, then runs the fallback killall cleanup command on every
+// server.
+func killAllProcesses() {
 	fmt.Println("Killing all processes...")
 
-	// Kill local processes
-	for name, cmd := range runningProcesses {
-		killLocalProcess(name, cmd)
+	processMutex.Lock()
+	names := make([]string, 0, len(runningProcesses)+len(runningSessions))
+	for name := range runningProcesses {
+		names = append(names, name)
 	}
+	for name := range runningSessions {
+		names = append(names, name)
+	}
+	// Tell the supervisor loops not to restart any of these once they exit.
+	for _, name := range names {
+		markIntentionalStop(name)
+	}
+	processMutex.Unlock()
 
-	// Kill SSH sessions
-	for name, session := range runningSessions {
-		killSSHSession(name, session)
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			stopProcessGracefully(name)
+		}(name)
 	}
+	wg.Wait()
 
 	// Stop all metrics checkers
+	processMutex.Lock()
 	for name, stopChan := range metricsStopChannels {
 		select {
 		case stopChan <- true:
@@ -713,22 +872,20 @@ func killAllProcesses() {
 			// Channel already has a signal or is closed
 		}
 	}
-
-	// Clear the maps
-	runningProcesses = make(map[string]*exec.Cmd)
-	runningSessions = make(map[string]*ssh.Session)
 	metricsStopChannels = make(map[string]chan bool)
+	processMutex.Unlock()
 
 	// Run fallback killall commands on all servers
-	for _, server := range config.Servers {
-		if server.SSHClient != nil {
+	cfg := getConfig()
+	for _, server := range cfg.Servers {
+		if server.Host != "" && server.Host != "localhost" {
 			fmt.Printf("Running fallback cleanup commands on remote server %s...\n", server.Host)
 
-			session, err := server.SSHClient.NewSession()
+			session, err := GetSession(server.Host)
 			if err != nil {
 				fmt.Printf("Failed to create SSH session for cleanup on %s: %v\n", server.Host, err)
 			} else {
-				cleanupCmd := config.Commands["cleanup"]
+				cleanupCmd := cfg.Commands["cleanup"]
 				killallCmd := fmt.Sprintf("%s %s", cleanupCmd.Command, strings.Join(cleanupCmd.Args, " "))
 				fmt.Printf("Executing cleanup command on %s: %s\n", server.Host, killallCmd)
 
@@ -741,8 +898,8 @@ func killAllProcesses() {
 			}
 		} else if server.Host == "" || server.Host == "localhost" {
 			fmt.Printf("Running fallback cleanup commands on localhost...\n")
-			
-			cleanupCmd := config.Commands["cleanup"]
+
+			cleanupCmd := cfg.Commands["cleanup"]
 			fmt.Printf("Executing cleanup command locally: %s %s\n", cleanupCmd.Command, strings.Join(cleanupCmd.Args, " "))
 			
 			cmd := exec.Command(cleanupCmd.Command, cleanupCmd.Args...)
@@ -756,194 +913,141 @@ func killAllProcesses() {
 }
 
 func initConfig() error {
-	configPath := "./config.json"
+	configPath = "./config.json"
 
 	// First CLI argument is config file path
 	if len(os.Args) > 1 {
 		configPath = os.Args[1]
 	}
 
-	var err error
-	config, err = loadConfig(configPath)
+	cfg, err := loadConfig(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to load config from %s: %v", configPath, err)
 	}
+	setConfig(cfg)
+
+	if info, err := os.Stat(configPath); err == nil {
+		configFileModTime = info.ModTime()
+	}
 
 	// Parse and cache check interval
-	checkInterval, err = time.ParseDuration(config.CheckInterval)
+	interval, err := time.ParseDuration(cfg.CheckInterval)
 	if err != nil {
-		fmt.Printf("Error parsing checkInterval '%s', using default 5s: %v\n", config.CheckInterval, err)
-		checkInterval = 5 * time.Second
+		fmt.Printf("Error parsing checkInterval '%s', using default 5s: %v\n", cfg.CheckInterval, err)
+		interval = 5 * time.Second
 	}
+	setCheckInterval(interval)
 
 	// Calculate total client count
-	totalClientCount = 0
-	for _, server := range config.Servers {
-		totalClientCount += server.ClientCount
+	count := 0
+	for _, server := range cfg.Servers {
+		count += server.ClientCount
 	}
+	setTotalClientCount(count)
+
+	configureLogger(cfg.LogLevel)
 
 	fmt.Printf("Loaded configuration from: %s\n", configPath)
 	return nil
 }
 
-func main() {
-	if err := initConfig(); err != nil {
-		log.Fatalf("Configuration error: %v", err)
-	}
-
-	// Connect to all SSH servers
-	for i := range config.Servers {
-		if config.Servers[i].Host != "" && config.Servers[i].Host != "localhost" {
-			client, err := connectSSH(config.Servers[i].Host)
-			if err != nil {
-				log.Fatalf("Failed to connect to SSH server %s: %v", config.Servers[i].Host, err)
+// runStartupSequence kills anything left running from a previous run, then
+// starts the coordinator and (once it's ready) every configured server's
+// clients. It's launched in its own goroutine by Launcher.Start so Start
+// can return as soon as the HTTP server is listening.
+func runStartupSequence(ctx context.Context) {
+	cfg := getConfig()
+	fmt.Println("Killing any existing processes...")
+
+	// Kill existing processes on all servers
+	cleanupCmd := cfg.Commands["cleanup"]
+	for _, server := range cfg.Servers {
+		if server.Host == "" || server.Host == "localhost" {
+			// Local execution
+			cmdStr := fmt.Sprintf("%s %s", cleanupCmd.Command, strings.Join(cleanupCmd.Args, " "))
+			fmt.Printf("Executing cleanup command locally: %s\n", cmdStr)
+			killCmd := exec.Command(cleanupCmd.Command, cleanupCmd.Args...)
+			if err := killCmd.Run(); err != nil {
+				fmt.Printf("Local cleanup command completed (some processes may not have existed): %v\n", err)
+			} else {
+				fmt.Printf("Local cleanup command completed successfully\n")
 			}
-			config.Servers[i].SSHClient = client
-			fmt.Printf("Connected to SSH server: %s\n", config.Servers[i].Host)
 		}
 	}
 
-	// Cleanup SSH connections on exit
-	defer func() {
-		for _, server := range config.Servers {
-			if server.SSHClient != nil {
-				server.SSHClient.Close()
-			}
+	// Find coordinator server
+	var coordServer *ServerConfig
+	for i := range cfg.Servers {
+		if cfg.Servers[i].Host == cfg.CoordinatorHost {
+			coordServer = &cfg.Servers[i]
+			break
 		}
-	}()
+	}
+	if coordServer == nil {
+		log.Fatalf("Coordinator host %s not found in servers list", cfg.CoordinatorHost)
+	}
 
-	http.HandleFunc("/ws", handleWebSocket)
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "index.html")
+	// Start coordinator
+	fmt.Printf("[MAIN] Starting coordinator on server: %s\n", coordServer.Host)
+	coordCmd := cfg.Commands["coordinator"]
+	coordUsesSSH := coordServer.Host != "" && coordServer.Host != "localhost"
+	registerProcessSpec("coordinator", processSpec{
+		cmdCfg:        coordCmd,
+		isCoordinator: true,
+		useSSH:        coordUsesSSH,
+		serverHost:    coordServer.Host,
+		workingDir:    coordServer.WorkingDirectory,
 	})
+	startSupervised(ctx, "coordinator", coordCmd, true, nil, coordUsesSSH, coordServer.Host, coordServer.WorkingDirectory)
 
-	go func() {
-		fmt.Println("Killing any existing processes...")
-
-		// Kill existing processes on all servers
-		cleanupCmd := config.Commands["cleanup"]
-		for _, server := range config.Servers {
-			if server.Host == "" || server.Host == "localhost" {
-				// Local execution
-				cmdStr := fmt.Sprintf("%s %s", cleanupCmd.Command, strings.Join(cleanupCmd.Args, " "))
-				fmt.Printf("Executing cleanup command locally: %s\n", cmdStr)
-				killCmd := exec.Command(cleanupCmd.Command, cleanupCmd.Args...)
-				if err := killCmd.Run(); err != nil {
-					fmt.Printf("Local cleanup command completed (some processes may not have existed): %v\n", err)
-				} else {
-					fmt.Printf("Local cleanup command completed successfully\n")
-				}
-			}
-		}
-
-		// Find coordinator server
-		var coordServer *ServerConfig
-		for i := range config.Servers {
-			if config.Servers[i].Host == config.CoordinatorHost {
-				coordServer = &config.Servers[i]
-				break
-			}
-		}
-		if coordServer == nil {
-			log.Fatalf("Coordinator host %s not found in servers list", config.CoordinatorHost)
-		}
-
-		// Start coordinator
-		fmt.Printf("[MAIN] Starting coordinator on server: %s\n", coordServer.Host)
-		coordCmd := config.Commands["coordinator"]
-		runProcess("coordinator", coordCmd.Command, coordCmd.Args, true, nil, coordServer.SSHClient, coordServer.Host, coordServer.WorkingDirectory)
+	fmt.Printf("[MAIN] Waiting for coordinator to be ready...\n")
+	readyTimer := timerpool.Get(coordinatorReadyTimeout)
+	defer timerpool.Put(readyTimer)
+	select {
+	case <-coordinatorReady:
+		fmt.Printf("[MAIN] Coordinator is ready! Starting clients...\n")
+	case <-ctx.Done():
+		fmt.Printf("[MAIN] Shutting down before coordinator became ready, not starting clients.\n")
+		return
+	case <-readyTimer.C:
+		fmt.Printf("[MAIN] WARNING: Coordinator ready timeout after %s, starting clients anyway...\n", coordinatorReadyTimeout)
+	}
+	updateProcessState("coordinator", "status", "ready", nil)
+	broadcastMessage("status", "coordinator", "ready")
 
-		fmt.Printf("[MAIN] Waiting for coordinator to be ready...\n")
-		select {
-		case <-coordinatorReady:
-			fmt.Printf("[MAIN] Coordinator is ready! Starting clients...\n")
-		case <-time.After(300 * time.Second): // 5 minute timeout
-			fmt.Printf("[MAIN] WARNING: Coordinator ready timeout after 5 minutes, starting clients anyway...\n")
-		}
-		updateProcessState("coordinator", "status", "ready", nil)
-		broadcastMessage("status", "coordinator", "ready")
-
-		// Start clients across all servers (after coordinator is ready)
-		clientIndex := 1
-		coordinatorHost := getMetricsHost(coordServer.Host) // Get the coordinator's host
-
-		for _, server := range config.Servers {
-			port := server.MetricsStartPort
-			for i := 0; i < server.ClientCount; i++ {
-				clientName := fmt.Sprintf("%s-client%d", getServerName(server.Host), clientIndex)
-
-				// Set up environment variables
-				env := []string{fmt.Sprintf("METRICS_LOCAL_BIND=0.0.0.0:%d", port)}
-
-				// If this client is on a different server than the coordinator, set RPC endpoints
-				if server.Host != coordServer.Host {
-					env = append(env, fmt.Sprintf("RPC=http://%s:8899", coordinatorHost))
-					env = append(env, fmt.Sprintf("WS_RPC=ws://%s:8900", coordinatorHost))
-					fmt.Printf("[CLIENT] Setting RPC endpoints for %s: RPC=http://%s:8899, WS_RPC=ws://%s:8900\n", clientName, coordinatorHost, coordinatorHost)
-				}
+	// Start clients across all servers (after coordinator is ready)
+	coordinatorHost := getMetricsHost(coordServer.Host) // Get the coordinator's host
 
-				clientPorts[clientName] = port
+	for i := range cfg.Servers {
+		server := &cfg.Servers[i]
+		names := startClientsForServer(server, coordinatorHost, coordServer.Host)
+		serverClientNames[server.Host] = append(serverClientNames[server.Host], names...)
+	}
+}
 
-				// Start client process
-				clientCmd := config.Commands["client"]
-				runProcess(clientName, clientCmd.Command, clientCmd.Args, false, env, server.SSHClient, server.Host, server.WorkingDirectory)
-				go checkMetricsPort(clientName, server.Host, port)
+func main() {
+	launcher, err := NewLauncher()
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+	activeLauncher = launcher
 
-				clientIndex++
-				port++
-			}
-		}
-	}()
+	if err := launcher.Start(context.Background()); err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
 		fmt.Println("\nReceived shutdown signal (Ctrl+C). Gracefully shutting down...")
-
-		// Create a timeout channel for cleanup
-		done := make(chan bool)
-		go func() {
-			// Use the comprehensive killAllProcesses function
-			killAllProcesses()
-
-			// Close SSH connections
-			for _, server := range config.Servers {
-				if server.SSHClient != nil {
-					fmt.Printf("Closing SSH connection to %s...\n", server.Host)
-					server.SSHClient.Close()
-				}
-			}
-			done <- true
-		}()
-
-		// Wait for cleanup or timeout
-		select {
-		case <-done:
-			fmt.Println("Graceful shutdown complete.")
-		case <-time.After(15 * time.Second):
-			fmt.Println("Cleanup timeout reached. Forcing shutdown.")
-		}
-		
-		os.Exit(0)
+		stopCtx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		launcher.Stop(stopCtx, true)
 	}()
 
-	fmt.Printf("Server running on http://localhost:%d with %d total clients across %d servers\n", config.Port, totalClientCount, len(config.Servers))
-	for _, server := range config.Servers {
-		if server.Host == "" || server.Host == "localhost" {
-			fmt.Printf("  Local: %d clients\n", server.ClientCount)
-		} else {
-			fmt.Printf("  %s: %d clients\n", server.Host, server.ClientCount)
-		}
+	<-launcher.Done()
+	if launcher.StopErr() != nil {
+		os.Exit(1)
 	}
-	
-	server := &http.Server{Addr: fmt.Sprintf(":%d", config.Port)}
-	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal(err)
-		}
-	}()
-	
-	// Wait for shutdown signal
-	select {}
 }
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricSample is one parsed OpenMetrics/Prometheus sample: a single time
+// series point belonging to a counter, gauge, or histogram bucket/sum/count.
+type MetricSample struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+	Type   string            `json:"type"`
+}
+
+// newMetricsHTTPClient builds the client used to scrape a training client's
+// /metrics endpoint, honoring the configured TLS verification policy.
+func newMetricsHTTPClient(cfg MetricsConfig) *http.Client {
+	transport := &http.Transport{}
+	if cfg.TLS && cfg.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: transport,
+	}
+}
+
+// scrapeMetrics fetches and parses the OpenMetrics/Prometheus exposition
+// text served at host:port+cfg.Path, applying the configured bearer token,
+// then filters and renames the result per cfg.
+func scrapeMetrics(client *http.Client, host string, port int, cfg MetricsConfig) ([]MetricSample, error) {
+	scheme := "http"
+	if cfg.TLS {
+		scheme = "https"
+	}
+	path := cfg.Path
+	if path == "" {
+		path = "/metrics"
+	}
+	url := fmt.Sprintf("%s://%s:%d%s", scheme, host, port, path)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build metrics request: %w", err)
+	}
+	if cfg.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.BearerToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrape metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metrics endpoint returned status %d", resp.StatusCode)
+	}
+
+	samples, err := parseOpenMetrics(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return filterAndRenameSamples(samples, cfg), nil
+}
+
+// parseOpenMetrics parses the Prometheus/OpenMetrics text exposition format:
+// "# HELP"/"# TYPE" comments, bare "name{labels} value" samples, and the
+// "_bucket{le=...}"/"_sum"/"_count" series a histogram expands into.
+func parseOpenMetrics(r io.Reader) ([]MetricSample, error) {
+	types := make(map[string]string)
+	var samples []MetricSample
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "# TYPE") {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 {
+				types[fields[2]] = fields[3]
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, labels, value, err := parseMetricLine(line)
+		if err != nil {
+			// Tolerate a malformed line rather than aborting the whole scrape.
+			continue
+		}
+
+		samples = append(samples, MetricSample{
+			Name:   name,
+			Labels: labels,
+			Value:  value,
+			Type:   metricTypeFor(name, types),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read metrics body: %w", err)
+	}
+
+	return samples, nil
+}
+
+// metricTypeFor resolves a sample's declared TYPE, stripping the
+// "_bucket"/"_sum"/"_count" suffixes a histogram's TYPE line is declared
+// against so each expanded series still reports "histogram".
+func metricTypeFor(name string, types map[string]string) string {
+	if t, ok := types[name]; ok {
+		return t
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if base := strings.TrimSuffix(name, suffix); base != name {
+			if t, ok := types[base]; ok {
+				return t
+			}
+		}
+	}
+	return "untyped"
+}
+
+// parseMetricLine splits a single exposition line of the form
+// `name{label="value",...} 1.23` into its components.
+func parseMetricLine(line string) (string, map[string]string, float64, error) {
+	if idx := strings.IndexByte(line, '{'); idx >= 0 {
+		end := strings.IndexByte(line[idx:], '}')
+		if end < 0 {
+			return "", nil, 0, fmt.Errorf("unterminated label set: %s", line)
+		}
+		end += idx
+
+		name := strings.TrimSpace(line[:idx])
+		labels := map[string]string{}
+		for _, pair := range splitLabels(line[idx+1 : end]) {
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok {
+				continue
+			}
+			labels[strings.TrimSpace(k)] = strings.Trim(strings.TrimSpace(v), `"`)
+		}
+
+		value, err := parseSampleValue(strings.TrimSpace(line[end+1:]))
+		if err != nil {
+			return "", nil, 0, err
+		}
+		return name, labels, value, nil
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return "", nil, 0, fmt.Errorf("malformed metric line: %s", line)
+	}
+	value, err := parseSampleValue(fields[1])
+	if err != nil {
+		return "", nil, 0, err
+	}
+	return fields[0], nil, value, nil
+}
+
+// splitLabels splits a `key="value",key2="value2"` label body on commas
+// that aren't inside a quoted value.
+func splitLabels(body string) []string {
+	var parts []string
+	var inQuotes bool
+	start := 0
+	for i, r := range body {
+		switch r {
+		case '"':
+			inQuotes = !inQuotes
+		case ',':
+			if !inQuotes {
+				parts = append(parts, body[start:i])
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, body[start:])
+	return parts
+}
+
+// parseSampleValue parses a metric's value field, which OpenMetrics allows
+// to carry a trailing timestamp ("value timestamp") alongside the float.
+func parseSampleValue(field string) (float64, error) {
+	fields := strings.Fields(field)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("missing metric value")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// filterAndRenameSamples applies the operator-configured allowlist and
+// per-metric renames so only the series an operator cares about reach the
+// dashboard, under whatever name it expects.
+func filterAndRenameSamples(samples []MetricSample, cfg MetricsConfig) []MetricSample {
+	if len(cfg.Allowlist) == 0 && len(cfg.Rename) == 0 {
+		return samples
+	}
+
+	allow := make(map[string]bool, len(cfg.Allowlist))
+	for _, name := range cfg.Allowlist {
+		allow[name] = true
+	}
+
+	filtered := samples[:0]
+	for _, s := range samples {
+		if len(allow) > 0 && !allow[s.Name] {
+			continue
+		}
+		if renamed, ok := cfg.Rename[s.Name]; ok {
+			s.Name = renamed
+		}
+		filtered = append(filtered, s)
+	}
+	return filtered
+}
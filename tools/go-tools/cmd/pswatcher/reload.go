@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// serverClientNames maps a server's Host key to the client process names
+// started for it, so reloadConfig knows which processes to stop when a
+// server is removed from config.
+var serverClientNames = make(map[string][]string)
+
+var (
+	clientIndexMu   sync.Mutex
+	nextClientIndex = 1
+)
+
+// allocateClientIndex hands out a globally-unique, monotonically
+// increasing client index, shared between the initial startup loop and any
+// servers added later by reloadConfig, so client names never collide.
+func allocateClientIndex() int {
+	clientIndexMu.Lock()
+	defer clientIndexMu.Unlock()
+	idx := nextClientIndex
+	nextClientIndex++
+	return idx
+}
+
+// startClientsForServer starts server.ClientCount supervised client
+// processes for server, wiring RPC endpoints to the coordinator when the
+// client isn't co-located with it. It returns the client names started.
+func startClientsForServer(server *ServerConfig, coordinatorHost, coordinatorServerHost string) []string {
+	clientCmd := getConfig().Commands["client"]
+	port := server.MetricsStartPort
+	var names []string
+
+	for i := 0; i < server.ClientCount; i++ {
+		clientName := fmt.Sprintf("%s-client%d", getServerName(server.Host), allocateClientIndex())
+
+		env := []string{fmt.Sprintf("METRICS_LOCAL_BIND=0.0.0.0:%d", port)}
+		if server.Host != coordinatorServerHost {
+			env = append(env, fmt.Sprintf("RPC=http://%s:8899", coordinatorHost))
+			env = append(env, fmt.Sprintf("WS_RPC=ws://%s:8900", coordinatorHost))
+			fmt.Printf("[CLIENT] Setting RPC endpoints for %s: RPC=http://%s:8899, WS_RPC=ws://%s:8900\n", clientName, coordinatorHost, coordinatorHost)
+		}
+
+		clientPorts[clientName] = port
+		useSSH := server.Host != "" && server.Host != "localhost"
+		registerProcessSpec(clientName, processSpec{
+			cmdCfg:     clientCmd,
+			env:        env,
+			useSSH:     useSSH,
+			serverHost: server.Host,
+			workingDir: server.WorkingDirectory,
+			port:       port,
+		})
+		startSupervised(runCtx, clientName, clientCmd, false, env, useSSH, server.Host, server.WorkingDirectory)
+		go checkMetricsPort(runCtx, clientName, server.Host, port)
+
+		names = append(names, clientName)
+		port++
+	}
+
+	return names
+}
+
+// startReloadWatchers wires up SIGHUP-triggered config reload plus an
+// mtime-polling fallback, so operators don't have to kill the whole
+// dashboard (and lose its websocket/SSH state) to add a server or change a
+// client count.
+func startReloadWatchers(sigCh <-chan os.Signal) {
+	go func() {
+		for range sigCh {
+			fmt.Println("Received SIGHUP, reloading configuration...")
+			if err := reloadConfig(); err != nil {
+				fmt.Printf("Config reload failed: %v\n", err)
+			}
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			info, err := os.Stat(configPath)
+			if err != nil {
+				continue
+			}
+			if info.ModTime().After(configFileModTime) {
+				fmt.Println("Detected config file change on disk, reloading configuration...")
+				if err := reloadConfig(); err != nil {
+					fmt.Printf("Config reload failed: %v\n", err)
+				}
+			}
+		}
+	}()
+}
+
+// sameExceptClientCount reports whether a and b describe the same server
+// modulo ClientCount, i.e. nothing that would require tearing down its
+// already-running clients (working directory, metrics port base, env,
+// PATH, host key checking) has changed.
+func sameExceptClientCount(a, b ServerConfig) bool {
+	return a.Host == b.Host &&
+		a.WorkingDirectory == b.WorkingDirectory &&
+		a.MetricsStartPort == b.MetricsStartPort &&
+		a.InsecureIgnoreHostKey == b.InsecureIgnoreHostKey &&
+		a.PathPrepend == b.PathPrepend &&
+		slices.Equal(a.Env, b.Env)
+}
+
+// stopClientsForServer kills and forgets every client process tracked for
+// host under serverClientNames, leaving its SSH connection (if any) open.
+func stopClientsForServer(host string) {
+	for _, name := range serverClientNames[host] {
+		killProcess(name)
+		forgetProcessSpec(name)
+		delete(clientPorts, name)
+	}
+	delete(serverClientNames, host)
+}
+
+// reconcileServer brings host's running clients in line with newServer. A
+// ClientCount-only change starts or stops just the delta of client
+// processes; any other field change isn't safe to apply to an
+// already-running process (it would need a different MetricsStartPort, Env,
+// etc.), so the server's clients are fully restarted under the new config.
+func reconcileServer(oldServer, newServer *ServerConfig, coordinatorHost, coordinatorServerHost string) {
+	host := newServer.Host
+
+	if !sameExceptClientCount(*oldServer, *newServer) {
+		fmt.Printf("[RELOAD] Server %s config changed, restarting its clients\n", host)
+		stopClientsForServer(host)
+		names := startClientsForServer(newServer, coordinatorHost, coordinatorServerHost)
+		serverClientNames[host] = names
+		return
+	}
+
+	delta := newServer.ClientCount - oldServer.ClientCount
+	switch {
+	case delta > 0:
+		fmt.Printf("[RELOAD] Server %s clientCount %d -> %d, starting %d more client(s)\n", host, oldServer.ClientCount, newServer.ClientCount, delta)
+		added := *newServer
+		added.ClientCount = delta
+		added.MetricsStartPort = newServer.MetricsStartPort + oldServer.ClientCount
+		names := startClientsForServer(&added, coordinatorHost, coordinatorServerHost)
+		serverClientNames[host] = append(serverClientNames[host], names...)
+	case delta < 0:
+		fmt.Printf("[RELOAD] Server %s clientCount %d -> %d, stopping %d client(s)\n", host, oldServer.ClientCount, newServer.ClientCount, -delta)
+		existing := serverClientNames[host]
+		stopCount := -delta
+		if stopCount > len(existing) {
+			stopCount = len(existing)
+		}
+		toStop := existing[len(existing)-stopCount:]
+		for _, name := range toStop {
+			killProcess(name)
+			forgetProcessSpec(name)
+			delete(clientPorts, name)
+		}
+		serverClientNames[host] = existing[:len(existing)-stopCount]
+	}
+}
+
+// reloadConfig re-reads configPath and diffs it against the running
+// configuration: servers removed from config have their clients stopped
+// and their SSH connection closed, servers added to config are connected
+// and have their clients spawned, servers present in both are reconciled
+// field-by-field by reconcileServer (so a changed ClientCount actually
+// starts/stops the delta instead of being silently ignored), and a changed
+// checkInterval re-parameterizes the running metrics loops (they read it
+// via getCheckInterval on every iteration, so no restart is needed).
+// Processes already running are otherwise left alone.
+func reloadConfig() error {
+	newCfg, err := loadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config from %s: %w", configPath, err)
+	}
+
+	oldCfg := getConfig()
+	oldServers := make(map[string]ServerConfig, len(oldCfg.Servers))
+	for _, s := range oldCfg.Servers {
+		oldServers[s.Host] = s
+	}
+	newServers := make(map[string]ServerConfig, len(newCfg.Servers))
+	for _, s := range newCfg.Servers {
+		newServers[s.Host] = s
+	}
+
+	// Removed servers: stop their clients and close their SSH connection.
+	for host, oldServer := range oldServers {
+		if _, present := newServers[host]; present {
+			continue
+		}
+		fmt.Printf("[RELOAD] Server %s removed from config, stopping its clients\n", host)
+		stopClientsForServer(host)
+		if oldServer.Host != "" && oldServer.Host != "localhost" {
+			closeSSHPool(oldServer.Host)
+		}
+	}
+
+	if newCfg.CheckInterval != oldCfg.CheckInterval {
+		if parsed, err := time.ParseDuration(newCfg.CheckInterval); err == nil {
+			setCheckInterval(parsed)
+			fmt.Printf("[RELOAD] checkInterval changed to %s\n", parsed)
+		} else {
+			fmt.Printf("[RELOAD] Invalid checkInterval %q, keeping %s: %v\n", newCfg.CheckInterval, getCheckInterval(), err)
+		}
+	}
+
+	configureLogger(newCfg.LogLevel)
+	setConfig(newCfg)
+
+	count := 0
+	for _, server := range newCfg.Servers {
+		count += server.ClientCount
+	}
+	setTotalClientCount(count)
+
+	coordinatorHost := ""
+	for i := range newCfg.Servers {
+		if newCfg.Servers[i].Host == newCfg.CoordinatorHost {
+			coordinatorHost = getMetricsHost(newCfg.Servers[i].Host)
+			break
+		}
+	}
+
+	for i := range newCfg.Servers {
+		server := &newCfg.Servers[i]
+		oldServer, existed := oldServers[server.Host]
+		if !existed {
+			fmt.Printf("[RELOAD] Server %s added to config, connecting\n", server.Host)
+			if server.Host != "" && server.Host != "localhost" {
+				if err := ensureSSHConnected(server.Host); err != nil {
+					fmt.Printf("[RELOAD] Failed to connect to SSH server %s: %v\n", server.Host, err)
+					continue
+				}
+			}
+			names := startClientsForServer(server, coordinatorHost, newCfg.CoordinatorHost)
+			serverClientNames[server.Host] = append(serverClientNames[server.Host], names...)
+			continue
+		}
+
+		// Present in both: reconcile in place. Its pooled SSH connection (if
+		// any) is keyed by host and is left as-is either way.
+		reconcileServer(&oldServer, server, coordinatorHost, newCfg.CoordinatorHost)
+	}
+
+	if info, err := os.Stat(configPath); err == nil {
+		configFileModTime = info.ModTime()
+	}
+
+	fmt.Println("[RELOAD] Configuration reload complete")
+	return nil
+}
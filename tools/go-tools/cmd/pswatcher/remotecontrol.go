@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/PsycheFoundation/psyche/tools/go-tools/internal/timerpool"
+)
+
+// remoteSignalTimeout bounds how long signalRemoteProcessGroup waits for its
+// one-off "write to the control FIFO" SSH session to complete, independent
+// of how long the signaled process itself then takes to act on the signal.
+const remoteSignalTimeout = 10 * time.Second
+
+// remoteControlPath returns the path of the control FIFO a command wrapped
+// by wrapRemoteCommand listens on for forwarded signals, scoped by name so
+// concurrent clients on the same server don't collide.
+func remoteControlPath(name string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	return fmt.Sprintf("/tmp/pswatcher-ctl-%s", safe)
+}
+
+// wrapRemoteCommand wraps cmdStr so it runs as the leader of its own process
+// group (via setsid) with a control FIFO forwarder alongside it, instead of
+// as a plain child of the SSH session's login shell. Without this, a signal
+// sent over the SSH "signal" channel request only reaches the shell SSH
+// handed the command to -- not reliably the command's own process group --
+// and a dropped SSH session orphans the remote process instead of it
+// receiving SIGTERM. signalRemoteProcessGroup delivers signals to the
+// wrapped command by writing them to this FIFO, which the backgrounded
+// reader forwards to the whole process group with `kill -s`.
+func wrapRemoteCommand(name, cmdStr string) string {
+	ctl := remoteControlPath(name)
+	return fmt.Sprintf(
+		`(ctl=%[1]q; rm -f "$ctl" 2>/dev/null; mkfifo "$ctl" || exit 1; exec 3<>"$ctl"; `+
+			`setsid %[2]s & cpid=$!; `+
+			`(while read -r sig <&3; do [ -n "$sig" ] || continue; kill -s "$sig" -- -$cpid 2>/dev/null; [ "$sig" = "KILL" ] && break; done) & fwdpid=$!; `+
+			`wait "$cpid"; ec=$?; kill "$fwdpid" 2>/dev/null; rm -f "$ctl" 2>/dev/null; exit "$ec")`,
+		ctl, cmdStr,
+	)
+}
+
+// signalRemoteProcessGroup forwards sig to name's remote process group by
+// writing it to the control FIFO the wrapRemoteCommand wrapper is reading
+// from, over a short-lived SSH session of its own -- so the real child (not
+// just the login shell SSH handed the command to) receives the signal the
+// coordinator/client actually handle, the same as a local process group get
+// via signalLocalProcessGroup.
+func signalRemoteProcessGroup(serverHost, name string, sig ssh.Signal) error {
+	session, err := GetSession(serverHost)
+	if err != nil {
+		return fmt.Errorf("open control session for %s: %w", name, err)
+	}
+	defer session.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(fmt.Sprintf("echo %s > %s", sig, remoteControlPath(name)))
+	}()
+
+	t := timerpool.Get(remoteSignalTimeout)
+	defer timerpool.Put(t)
+
+	select {
+	case err := <-done:
+		return err
+	case <-t.C:
+		return fmt.Errorf("timed out forwarding %s to %s", sig, name)
+	}
+}
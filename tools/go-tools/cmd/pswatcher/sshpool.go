@@ -0,0 +1,318 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	sshDialTimeout      = 30 * time.Second
+	sshKeepaliveEvery   = 15 * time.Second
+	sshKeepaliveTimeout = 10 * time.Second
+	sshReconnectMin     = 1 * time.Second
+	sshReconnectMax     = 30 * time.Second
+)
+
+// pooledConn is one server's long-lived SSH connection, kept alive by a
+// background keepalive loop that notices a dead connection and redials with
+// exponential backoff, so a dropped TCP connection doesn't leave every
+// subsequent GetSession call failing until the whole watcher is restarted.
+type pooledConn struct {
+	mu     sync.Mutex
+	client *ssh.Client
+	stopCh chan struct{}
+}
+
+var (
+	sshPoolMu sync.Mutex
+	sshPool   = make(map[string]*pooledConn)
+)
+
+// poolFor returns serverHost's pooled connection, creating it (and starting
+// its keepalive loop) on first use.
+func poolFor(serverHost string) *pooledConn {
+	sshPoolMu.Lock()
+	defer sshPoolMu.Unlock()
+
+	conn, exists := sshPool[serverHost]
+	if !exists {
+		conn = &pooledConn{stopCh: make(chan struct{})}
+		sshPool[serverHost] = conn
+		go conn.keepaliveLoop(serverHost)
+	}
+	return conn
+}
+
+// GetSession returns a new SSH session to serverHost, dialing on first use
+// and transparently redialing if the pooled connection has gone dead since
+// the last call. Callers get a fresh *ssh.Session per call, same as calling
+// NewSession() on a client they held onto themselves.
+func GetSession(serverHost string) (*ssh.Session, error) {
+	conn := poolFor(serverHost)
+
+	conn.mu.Lock()
+	client := conn.client
+	conn.mu.Unlock()
+
+	if client != nil {
+		if session, err := client.NewSession(); err == nil {
+			return session, nil
+		}
+		fmt.Printf("[ssh:%s] pooled connection is dead, reconnecting\n", serverHost)
+		conn.replaceDeadClient(client)
+	}
+
+	client, err := dialSSH(serverHost)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", serverHost, err)
+	}
+	conn.mu.Lock()
+	conn.client = client
+	conn.mu.Unlock()
+
+	return client.NewSession()
+}
+
+// ensureSSHConnected eagerly dials serverHost and seeds the pool with the
+// result. Used at startup (and on reload, for newly-added servers) so a
+// misconfigured server is caught immediately with a clear error instead of
+// surfacing the first time some process tries to use it.
+func ensureSSHConnected(serverHost string) error {
+	conn := poolFor(serverHost)
+
+	client, err := dialSSH(serverHost)
+	if err != nil {
+		return err
+	}
+
+	conn.mu.Lock()
+	conn.client = client
+	conn.mu.Unlock()
+	return nil
+}
+
+// closeSSHPool tears down serverHost's pooled connection, if any, and stops
+// its keepalive loop so it doesn't immediately redial after we've asked it
+// to shut down.
+func closeSSHPool(serverHost string) {
+	sshPoolMu.Lock()
+	conn, exists := sshPool[serverHost]
+	if exists {
+		delete(sshPool, serverHost)
+	}
+	sshPoolMu.Unlock()
+
+	if !exists {
+		return
+	}
+
+	close(conn.stopCh)
+	conn.mu.Lock()
+	if conn.client != nil {
+		conn.client.Close()
+		conn.client = nil
+	}
+	conn.mu.Unlock()
+}
+
+func (c *pooledConn) replaceDeadClient(dead *ssh.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == dead {
+		c.client = nil
+	}
+	dead.Close()
+}
+
+// keepaliveLoop sends a keepalive@openssh.com request on a ticker for as
+// long as the pool entry exists, redialing with backoff the moment a
+// keepalive fails or times out so a replacement connection is usually
+// already warm by the time something next calls GetSession.
+func (c *pooledConn) keepaliveLoop(serverHost string) {
+	ticker := time.NewTicker(sshKeepaliveEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		c.mu.Lock()
+		client := c.client
+		c.mu.Unlock()
+
+		if client == nil {
+			c.dialWithBackoff(serverHost)
+			continue
+		}
+
+		errCh := make(chan error, 1)
+		go func() {
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			errCh <- err
+		}()
+
+		select {
+		case err := <-errCh:
+			if err != nil {
+				fmt.Printf("[ssh:%s] keepalive failed, reconnecting: %v\n", serverHost, err)
+				c.replaceDeadClient(client)
+				c.dialWithBackoff(serverHost)
+			}
+		case <-time.After(sshKeepaliveTimeout):
+			fmt.Printf("[ssh:%s] keepalive timed out, reconnecting\n", serverHost)
+			c.replaceDeadClient(client)
+			c.dialWithBackoff(serverHost)
+		case <-c.stopCh:
+			return
+		}
+	}
+}
+
+// dialWithBackoff retries dialSSH with exponential backoff (capped at
+// sshReconnectMax) until it succeeds or the pool entry is closed, so a
+// server that's mid-reboot gets picked back up automatically instead of
+// staying dead until an operator notices and restarts the watcher.
+func (c *pooledConn) dialWithBackoff(serverHost string) {
+	backoff := sshReconnectMin
+	for {
+		client, err := dialSSH(serverHost)
+		if err == nil {
+			c.mu.Lock()
+			c.client = client
+			c.mu.Unlock()
+			fmt.Printf("[ssh:%s] reconnected\n", serverHost)
+			return
+		}
+
+		fmt.Printf("[ssh:%s] reconnect failed, retrying in %s: %v\n", serverHost, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-c.stopCh:
+			return
+		}
+		backoff *= 2
+		if backoff > sshReconnectMax {
+			backoff = sshReconnectMax
+		}
+	}
+}
+
+// findServerConfig returns the ServerConfig for serverHost, or nil if no
+// server in the running config matches it (e.g. it was removed by a config
+// reload racing with an in-flight session request).
+func findServerConfig(serverHost string) *ServerConfig {
+	cfg := getConfig()
+	for i := range cfg.Servers {
+		if cfg.Servers[i].Host == serverHost {
+			return &cfg.Servers[i]
+		}
+	}
+	return nil
+}
+
+// dialSSH opens a new SSH connection to serverHost ("host" or "user@host",
+// defaulting to $USER).
+func dialSSH(serverHost string) (*ssh.Client, error) {
+	user := os.Getenv("USER")
+	host := serverHost
+	if strings.Contains(serverHost, "@") {
+		parts := strings.SplitN(serverHost, "@", 2)
+		user = parts[0]
+		host = parts[1]
+	}
+
+	authMethods, err := sshAuthMethods()
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeyCallback, err := sshHostKeyCallback(serverHost)
+	if err != nil {
+		return nil, err
+	}
+
+	clientConfig := &ssh.ClientConfig{
+		User:            user,
+		Auth:            authMethods,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sshDialTimeout,
+	}
+
+	return ssh.Dial("tcp", host+":22", clientConfig)
+}
+
+// sshAuthMethods offers ssh-agent keys first, so passphrase-protected keys
+// work without this process ever touching the passphrase, then falls back
+// to the id_rsa/id_ed25519 file lookup this already did, now also able to
+// decrypt a passphrase-protected key via PSWATCHER_SSH_KEY_PASSPHRASE.
+func sshAuthMethods() ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		} else {
+			fmt.Printf("[ssh] SSH_AUTH_SOCK is set but dialing it failed, skipping ssh-agent: %v\n", err)
+		}
+	}
+
+	methods = append(methods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		homeDir := os.Getenv("HOME")
+		keyPath := filepath.Join(homeDir, ".ssh", "id_rsa")
+		if _, err := os.Stat(keyPath); os.IsNotExist(err) {
+			keyPath = filepath.Join(homeDir, ".ssh", "id_ed25519")
+		}
+		key, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		signer, err := ssh.ParsePrivateKey(key)
+		if err == nil {
+			return []ssh.Signer{signer}, nil
+		}
+		if _, isPassphraseProtected := err.(*ssh.PassphraseMissingError); !isPassphraseProtected {
+			return nil, err
+		}
+
+		passphrase := os.Getenv("PSWATCHER_SSH_KEY_PASSPHRASE")
+		if passphrase == "" {
+			return nil, fmt.Errorf("key %s is passphrase-protected; set PSWATCHER_SSH_KEY_PASSPHRASE or load it into ssh-agent", keyPath)
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+		if err != nil {
+			return nil, err
+		}
+		return []ssh.Signer{signer}, nil
+	}))
+
+	return methods, nil
+}
+
+// sshHostKeyCallback verifies against ~/.ssh/known_hosts unless serverHost's
+// config opts into InsecureIgnoreHostKey, which should only be set for
+// throwaway/local test servers.
+func sshHostKeyCallback(serverHost string) (ssh.HostKeyCallback, error) {
+	if serverCfg := findServerConfig(serverHost); serverCfg != nil && serverCfg.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	knownHostsPath := filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts")
+	callback, err := knownhosts.New(knownHostsPath)
+	if err != nil {
+		return nil, fmt.Errorf("load known_hosts from %s (set insecureIgnoreHostKey for this server to skip verification): %w", knownHostsPath, err)
+	}
+	return callback, nil
+}
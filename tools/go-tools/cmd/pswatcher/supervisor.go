@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/PsycheFoundation/psyche/tools/go-tools/internal/timerpool"
+)
+
+const (
+	defaultStartSeconds = 5
+	defaultStartRetries = 3
+	backoffMin          = 1 * time.Second
+	backoffMax          = 30 * time.Second
+)
+
+// stoppedIntentionally marks processes whose most recent exit was caused by
+// an operator kill request rather than a crash, so startSupervised's retry
+// loop below knows to give up instead of restarting them.
+var (
+	stoppedIntentionallyMu sync.Mutex
+	stoppedIntentionally   = make(map[string]bool)
+)
+
+func markIntentionalStop(name string) {
+	stoppedIntentionallyMu.Lock()
+	stoppedIntentionally[name] = true
+	stoppedIntentionallyMu.Unlock()
+}
+
+func consumeIntentionalStop(name string) bool {
+	stoppedIntentionallyMu.Lock()
+	defer stoppedIntentionallyMu.Unlock()
+	if stoppedIntentionally[name] {
+		delete(stoppedIntentionally, name)
+		return true
+	}
+	return false
+}
+
+// startSupervised launches a managed process and keeps it running according
+// to cmdCfg's autorestart policy, modeled on supervisord: starting ->
+// running -> backoff -> fatal|stopped|finished. An exit that lands before
+// cmdCfg.StartSeconds of uptime counts as a fast failure; it is restarted
+// after up to cmdCfg.StartRetries consecutive fast failures, and the next
+// fast failure after that marks the process "fatal" and stops supervision. Restarts back off exponentially
+// between backoffMin and backoffMax.
+func startSupervised(ctx context.Context, name string, cmdCfg CommandConfig, isCoordinator bool, env []string, useSSH bool, serverHost, workingDir string) {
+	startSeconds := cmdCfg.StartSeconds
+	if startSeconds <= 0 {
+		startSeconds = defaultStartSeconds
+	}
+	startRetries := cmdCfg.StartRetries
+	if startRetries <= 0 {
+		startRetries = defaultStartRetries
+	}
+
+	go func() {
+		backoff := backoffMin
+		retries := 0
+
+		for {
+			startedAt := time.Now()
+			exitCh := make(chan error, 1)
+
+			runProcess(ctx, name, cmdCfg.Command, cmdCfg.Args, isCoordinator, env, useSSH, serverHost, workingDir, func(err error) {
+				exitCh <- err
+			})
+
+			exitErr := <-exitCh
+			ranFor := time.Since(startedAt)
+
+			if consumeIntentionalStop(name) {
+				updateProcessState(name, "status", "stopped", nil)
+				broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"stopped"}`, name))
+				return
+			}
+
+			restart := false
+			switch cmdCfg.Autorestart {
+			case "always":
+				restart = true
+			case "on-failure":
+				restart = exitErr != nil
+			}
+			if !restart {
+				// runProcess already broadcast the terminal "finished"/"failed" state.
+				return
+			}
+
+			if ranFor < time.Duration(startSeconds)*time.Second {
+				retries++
+			} else {
+				retries = 0
+				backoff = backoffMin
+			}
+
+			if retries > startRetries {
+				fmt.Printf("[%s] Exceeded %d start retries, marking fatal\n", name, startRetries)
+				updateProcessState(name, "status", "fatal", nil)
+				broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"fatal"}`, name))
+				return
+			}
+
+			fmt.Printf("[%s] Restarting in %s (retry %d/%d)\n", name, backoff, retries, startRetries)
+			updateProcessState(name, "status", "backoff", nil)
+			broadcast(fmt.Sprintf(`{"type":"status","name":"%s","status":"backoff"}`, name))
+
+			backoffTimer := timerpool.Get(backoff)
+			select {
+			case <-ctx.Done():
+				timerpool.Put(backoffTimer)
+				return
+			case <-backoffTimer.C:
+				timerpool.Put(backoffTimer)
+			}
+
+			backoff *= 2
+			if backoff > backoffMax {
+				backoff = backoffMax
+			}
+		}
+	}()
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
+	"time"
 
+	bin "github.com/gagliardetto/binary"
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/ws"
 )
 
 const (
@@ -15,14 +20,16 @@ const (
 )
 
 type CoordinatorClient struct {
-	rpcClient *rpc.Client
-	programID solana.PublicKey
+	rpcClient  *rpc.Client
+	wsEndpoint string
+	programID  solana.PublicKey
 }
 
-func NewCoordinatorClient(rpcEndpoint string, programID solana.PublicKey) *CoordinatorClient {
+func NewCoordinatorClient(rpcEndpoint, wsEndpoint string, programID solana.PublicKey) *CoordinatorClient {
 	return &CoordinatorClient{
-		rpcClient: rpc.New(rpcEndpoint),
-		programID: programID,
+		rpcClient:  rpc.New(rpcEndpoint),
+		wsEndpoint: wsEndpoint,
+		programID:  programID,
 	}
 }
 
@@ -51,8 +58,43 @@ type CoordinatorInstanceData struct {
 	JoinAuthority      solana.PublicKey
 	CoordinatorAccount solana.PublicKey
 	RunID              string
+	Version            string
+	// GPURequirement is only present on accounts created by coordinator
+	// program versions that publish it; nil otherwise.
+	GPURequirement *GPURequirement
 }
 
+// coordinatorInstanceLayout mirrors the Rust CoordinatorInstance account
+// struct field-for-field (sans its leading Anchor discriminator), so
+// bin.NewBorshDecoder can deserialize it directly instead of hand-rolled
+// byte-slicing.
+type coordinatorInstanceLayout struct {
+	Bump               uint8
+	MainAuthority      solana.PublicKey
+	JoinAuthority      solana.PublicKey
+	CoordinatorAccount solana.PublicKey
+	RunID              string
+	Version            string
+	GPURequirement     *gpuRequirementLayout `bin:"optional"`
+}
+
+type gpuRequirementLayout struct {
+	Count      int32
+	MinVRAMMiB uint64
+	Capability string
+}
+
+// anchorDiscriminator computes the 8-byte account discriminator Anchor
+// prefixes every account with: sha256("account:<TypeName>")[:8].
+func anchorDiscriminator(typeName string) [8]byte {
+	sum := sha256.Sum256([]byte("account:" + typeName))
+	var discriminator [8]byte
+	copy(discriminator[:], sum[:8])
+	return discriminator
+}
+
+var coordinatorInstanceDiscriminator = anchorDiscriminator("CoordinatorInstance")
+
 // Fetches and parses the CoordinatorInstance from Solana
 func (c *CoordinatorClient) fetchCoordinatorData(ctx context.Context, runID string) (*CoordinatorInstanceData, error) {
 	// Derive the coordinator instance PDA
@@ -71,68 +113,59 @@ func (c *CoordinatorClient) fetchCoordinatorData(ctx context.Context, runID stri
 		return nil, fmt.Errorf("coordinator instance not found on-chain")
 	}
 
-	accountData := accountInfo.Value.Data.GetBinary()
-
-	// - 8 bytes: Anchor discriminator
-	// - 1 byte: bump
-	// - 32 bytes: main_authority (Pubkey)
-	// - 32 bytes: join_authority (Pubkey)
-	// - 32 bytes: coordinator_account (Pubkey)
-	// - 4 bytes: run_id length (u32 little-endian)
-	// - N bytes: run_id string data
-	const (
-		DISCRIMINATOR_SIZE = 8
-		BUMP_SIZE          = 1
-		PUBKEY_SIZE        = 32
-		RUN_ID_SIZE        = 4
-	)
-
-	expectedMinSize := DISCRIMINATOR_SIZE + BUMP_SIZE + (3 * PUBKEY_SIZE) + RUN_ID_SIZE
-	if len(accountData) < expectedMinSize {
-		return nil, fmt.Errorf("account data too short: got %d bytes, expected at least %d", len(accountData), expectedMinSize)
+	instance, err := parseCoordinatorAccountData(accountInfo.Value.Data.GetBinary())
+	if err != nil {
+		return nil, err
 	}
 
-	offset := DISCRIMINATOR_SIZE
-
-	bumpByte := accountData[offset]
-	offset += BUMP_SIZE
-
-	mainAuthority := solana.PublicKeyFromBytes(accountData[offset : offset+PUBKEY_SIZE])
-	offset += PUBKEY_SIZE
+	fmt.Printf("Fetched CoordinatorInstance from chain: { run_id: %s, coordinator_account: %s }\n",
+		instance.RunID, instance.CoordinatorAccount.String())
 
-	joinAuthority := solana.PublicKeyFromBytes(accountData[offset : offset+PUBKEY_SIZE])
-	offset += PUBKEY_SIZE
+	return instance, nil
+}
 
-	coordinatorAccount := solana.PublicKeyFromBytes(accountData[offset : offset+PUBKEY_SIZE])
-	offset += PUBKEY_SIZE
+// parseCoordinatorAccountData decodes a raw CoordinatorInstance account
+// buffer, as returned by either GetAccountInfo or an accountSubscribe
+// notification. It validates the leading 8-byte Anchor discriminator before
+// handing the rest off to a Borsh decoder.
+func parseCoordinatorAccountData(accountData []byte) (*CoordinatorInstanceData, error) {
+	const discriminatorSize = 8
 
-	// Parse run_id string
-	runIDLen := uint32(accountData[offset]) |
-		uint32(accountData[offset+1])<<8 |
-		uint32(accountData[offset+2])<<16 |
-		uint32(accountData[offset+3])<<24
-	offset += RUN_ID_SIZE
+	if len(accountData) < discriminatorSize {
+		return nil, fmt.Errorf("account data too short: got %d bytes, expected at least %d", len(accountData), discriminatorSize)
+	}
 
-	if runIDLen > SOLANA_MAX_STRING_LEN {
-		return nil, fmt.Errorf("run_id length %d exceeds max %d", runIDLen, SOLANA_MAX_STRING_LEN)
+	if !bytes.Equal(accountData[:discriminatorSize], coordinatorInstanceDiscriminator[:]) {
+		return nil, fmt.Errorf("unexpected account discriminator: got %x, expected %x (not a CoordinatorInstance account?)",
+			accountData[:discriminatorSize], coordinatorInstanceDiscriminator)
 	}
 
-	if len(accountData) < offset+int(runIDLen) {
-		return nil, fmt.Errorf("insufficient data for run_id string")
+	var layout coordinatorInstanceLayout
+	decoder := bin.NewBorshDecoder(accountData[discriminatorSize:])
+	if err := decoder.Decode(&layout); err != nil {
+		return nil, fmt.Errorf("failed to Borsh-decode CoordinatorInstance: %w", err)
 	}
 
-	parsedRunID := string(accountData[offset : offset+int(runIDLen)])
+	if len(layout.RunID) > SOLANA_MAX_STRING_LEN {
+		return nil, fmt.Errorf("run_id length %d exceeds max %d", len(layout.RunID), SOLANA_MAX_STRING_LEN)
+	}
 
 	instance := &CoordinatorInstanceData{
-		Bump:               bumpByte,
-		MainAuthority:      mainAuthority,
-		JoinAuthority:      joinAuthority,
-		CoordinatorAccount: coordinatorAccount,
-		RunID:              parsedRunID,
+		Bump:               layout.Bump,
+		MainAuthority:      layout.MainAuthority,
+		JoinAuthority:      layout.JoinAuthority,
+		CoordinatorAccount: layout.CoordinatorAccount,
+		RunID:              layout.RunID,
+		Version:            layout.Version,
 	}
 
-	fmt.Printf("Fetched CoordinatorInstance from chain: { run_id: %s, coordinator_account: %s }\n",
-		instance.RunID, instance.CoordinatorAccount.String())
+	if layout.GPURequirement != nil {
+		instance.GPURequirement = &GPURequirement{
+			Count:      layout.GPURequirement.Count,
+			MinVRAMMiB: layout.GPURequirement.MinVRAMMiB,
+			Capability: layout.GPURequirement.Capability,
+		}
+	}
 
 	return instance, nil
 }
@@ -142,17 +175,35 @@ func (c *CoordinatorClient) GetDockerTagForRun(runID string) (string, error) {
 	ctx := context.Background()
 
 	// Fetch coordinator instance from Solana
-	_, err := c.fetchCoordinatorData(ctx, runID)
+	instance, err := c.fetchCoordinatorData(ctx, runID)
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch coordinator from Solana: %w", err)
 	}
 
-	// TODO: When version field is added to CoordinatorInstance, use it here:
-	// dockerTag := fmt.Sprintf("nousresearch/psyche-client:%s", instance.Version)
-	// return dockerTag, nil
+	return dockerTagForInstance(instance), nil
+}
+
+// dockerTagForInstance resolves the docker image tag a given
+// CoordinatorInstance snapshot requires.
+func dockerTagForInstance(instance *CoordinatorInstanceData) string {
+	version := instance.Version
+	if version == "" {
+		version = "latest"
+	}
+	return fmt.Sprintf("nousresearch/psyche-client:%s", version)
+}
+
+// GetGPURequirement fetches the run's on-chain GPU requirement, if the
+// coordinator program version publishes one.
+func (c *CoordinatorClient) GetGPURequirement(runID string) (*GPURequirement, error) {
+	ctx := context.Background()
+
+	instance, err := c.fetchCoordinatorData(ctx, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch coordinator from Solana: %w", err)
+	}
 
-	dockerTag := fmt.Sprintf("nousresearch/psyche-client:%s", "latest")
-	return dockerTag, nil
+	return instance.GPURequirement, nil
 }
 
 // Extracts the version from the docker tag "nousresearch/psyche-client:v1.2.3" -> "v1.2.3"
@@ -170,3 +221,116 @@ func (c *CoordinatorClient) GetRunVersion(runID string) (string, error) {
 
 	return "", fmt.Errorf("invalid docker tag format: %s", dockerTag)
 }
+
+const (
+	wsReconnectBackoffMin = 1 * time.Second
+	wsReconnectBackoffMax = 30 * time.Second
+	pollFallbackInterval  = 30 * time.Second
+)
+
+// WatchCoordinator subscribes to the coordinator instance account over
+// Solana's WebSocket RPC and pushes a decoded snapshot every time it
+// changes, reconnecting with backoff on WS drops. It also polls
+// GetAccountInfo on a timer as a fallback in case the WS subscription
+// silently stalls without closing. The returned channel is closed when ctx
+// is cancelled.
+func (c *CoordinatorClient) WatchCoordinator(ctx context.Context, runID string) (<-chan CoordinatorInstanceData, error) {
+	coordinatorInstance, _, err := c.findCoordinatorInstance(runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive coordinator PDA: %w", err)
+	}
+
+	out := make(chan CoordinatorInstanceData)
+
+	go func() {
+		defer close(out)
+
+		backoff := wsReconnectBackoffMin
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err := c.watchCoordinatorOnce(ctx, coordinatorInstance, out); err != nil {
+				fmt.Printf("[WatchCoordinator] WS subscription error, reconnecting in %s: %v\n", backoff, err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				backoff *= 2
+				if backoff > wsReconnectBackoffMax {
+					backoff = wsReconnectBackoffMax
+				}
+				continue
+			}
+
+			// watchCoordinatorOnce only returns nil if ctx was cancelled
+			return
+		}
+	}()
+
+	return out, nil
+}
+
+// watchCoordinatorOnce holds a single WS connection open, forwarding
+// decoded account updates to out, plus a periodic poll as a fallback for
+// stalled subscriptions. It returns nil only when ctx is cancelled.
+func (c *CoordinatorClient) watchCoordinatorOnce(ctx context.Context, coordinatorInstance solana.PublicKey, out chan<- CoordinatorInstanceData) error {
+	wsClient, err := ws.Connect(ctx, c.wsEndpoint)
+	if err != nil {
+		return fmt.Errorf("failed to connect to WS RPC: %w", err)
+	}
+	defer wsClient.Close()
+
+	sub, err := wsClient.AccountSubscribe(coordinatorInstance, rpc.CommitmentConfirmed)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to coordinator account: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	pollTicker := time.NewTicker(pollFallbackInterval)
+	defer pollTicker.Stop()
+
+	notifications := make(chan *ws.AccountResult)
+	subErr := make(chan error, 1)
+	go func() {
+		for {
+			result, err := sub.Recv(ctx)
+			if err != nil {
+				subErr <- err
+				return
+			}
+			notifications <- result
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case err := <-subErr:
+			return fmt.Errorf("WS subscription closed: %w", err)
+
+		case result := <-notifications:
+			instance, err := parseCoordinatorAccountData(result.Value.Data.GetBinary())
+			if err != nil {
+				fmt.Printf("[WatchCoordinator] failed to parse account update: %v\n", err)
+				continue
+			}
+			out <- *instance
+
+		case <-pollTicker.C:
+			accountInfo, err := c.rpcClient.GetAccountInfo(ctx, coordinatorInstance)
+			if err != nil || accountInfo.Value == nil {
+				continue
+			}
+			instance, err := parseCoordinatorAccountData(accountInfo.Value.Data.GetBinary())
+			if err != nil {
+				continue
+			}
+			out <- *instance
+		}
+	}
+}
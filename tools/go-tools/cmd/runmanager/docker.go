@@ -7,10 +7,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 )
 
 type DockerManager struct {
@@ -70,7 +73,25 @@ func (d *DockerManager) PullImage(ctx context.Context, imageName string) error {
 	return nil
 }
 
-func (d *DockerManager) RunContainer(ctx context.Context, imageName string, envVars []string) (string, error) {
+// ResolveDigest returns "<repo>@sha256:<digest>" for an already-pulled tag,
+// so the caller can pin the exact image content rather than a mutable tag.
+func (d *DockerManager) ResolveDigest(ctx context.Context, imageName string) (string, error) {
+	inspect, err := d.client.ImageInspect(ctx, imageName)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", imageName, err)
+	}
+
+	if len(inspect.RepoDigests) == 0 {
+		return "", fmt.Errorf("image %s has no RepoDigests (was it pulled from a registry?)", imageName)
+	}
+
+	// RepoDigests entries look like "repo@sha256:...."; take the first one,
+	// as Docker only lists digests for the registries the image was
+	// actually pulled from.
+	return inspect.RepoDigests[0], nil
+}
+
+func (d *DockerManager) RunContainer(ctx context.Context, imageName string, envVars []string, gpus *GPUOptions) (string, error) {
 	fmt.Printf("Creating container from image: %s\n", imageName)
 	fmt.Printf("Environment variables: %v\n", envVars)
 
@@ -80,16 +101,19 @@ func (d *DockerManager) RunContainer(ctx context.Context, imageName string, envV
 		Tty:   false,
 	}
 
-	hostConfig := &container.HostConfig{
-		// For GPU support:
-		// Resources: container.Resources{
-		// 	DeviceRequests: []container.DeviceRequest{
-		// 		{
-		// 			Count:        -1, // all GPUs
-		// 			Capabilities: [][]string{{"gpu"}},
-		// 		},
-		// 	},
-		// },
+	hostConfig := &container.HostConfig{}
+
+	if gpus != nil {
+		fmt.Printf("Requesting GPU devices: count=%d devices=%v capabilities=%v\n", gpus.Count, gpus.DeviceIDs, gpus.Capabilities)
+		hostConfig.Resources = container.Resources{
+			DeviceRequests: []container.DeviceRequest{
+				{
+					Count:        gpus.Count,
+					DeviceIDs:    gpus.DeviceIDs,
+					Capabilities: [][]string{gpus.Capabilities},
+				},
+			},
+		}
 	}
 
 	resp, err := d.client.ContainerCreate(ctx, config, hostConfig, nil, nil, "")
@@ -107,20 +131,150 @@ func (d *DockerManager) RunContainer(ctx context.Context, imageName string, envV
 	return containerID, nil
 }
 
-// Streams log to stdout
-func (d *DockerManager) StreamLogs(ctx context.Context, containerID string) error {
-	options := container.LogsOptions{
-		ShowStdout: true,
-		ShowStderr: true,
-		Follow:     true,
+// StopContainer stops a running container, waiting up to timeout for it to
+// exit on its own (a zero timeout defers to the daemon's own default grace
+// period), then waits for it to actually reach NotRunning.
+func (d *DockerManager) StopContainer(ctx context.Context, containerID string, timeout time.Duration) error {
+	fmt.Printf("Stopping container: %s (timeout: %s)\n", containerID, timeout)
+
+	stopOptions := container.StopOptions{}
+	if timeout > 0 {
+		secs := int(timeout.Seconds())
+		stopOptions.Timeout = &secs
 	}
 
-	reader, err := d.client.ContainerLogs(ctx, containerID, options)
+	if err := d.client.ContainerStop(ctx, containerID, stopOptions); err != nil {
+		return fmt.Errorf("failed to stop container: %w", err)
+	}
+
+	statusCh, errCh := d.client.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("failed waiting for container to stop: %w", err)
+		}
+	case <-statusCh:
+	}
+
+	return nil
+}
+
+// KillContainer immediately sends SIGKILL, for escalation when a second
+// shutdown signal arrives while a graceful stop is still in flight.
+func (d *DockerManager) KillContainer(ctx context.Context, containerID string) error {
+	fmt.Printf("Force killing container: %s\n", containerID)
+	if err := d.client.ContainerKill(ctx, containerID, "SIGKILL"); err != nil {
+		return fmt.Errorf("failed to kill container: %w", err)
+	}
+	return nil
+}
+
+// RemoveContainer deletes a stopped container.
+func (d *DockerManager) RemoveContainer(ctx context.Context, containerID string) error {
+	fmt.Printf("Removing container: %s\n", containerID)
+	if err := d.client.ContainerRemove(ctx, containerID, container.RemoveOptions{}); err != nil {
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+	return nil
+}
+
+func (d *DockerManager) isContainerRunning(ctx context.Context, containerID string) (bool, error) {
+	info, err := d.client.ContainerInspect(ctx, containerID)
 	if err != nil {
-		return fmt.Errorf("failed to get container logs: %w", err)
+		return false, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	return info.State != nil && info.State.Running, nil
+}
+
+// StreamLogs demultiplexes the Docker Engine's multiplexed log stream to
+// stdout/stderr, and reconnects with Since=<last seen timestamp> if the
+// stream ends while the container is still running (daemon restart,
+// connection reset), so no lines are lost and the caller never hangs.
+func (d *DockerManager) StreamLogs(ctx context.Context, containerID string, opts LogOptions) error {
+	since := ""
+
+	for {
+		logsOptions := container.LogsOptions{
+			ShowStdout: true,
+			ShowStderr: true,
+			Follow:     true,
+			Tail:       opts.Tail,
+			// Always request timestamps from the daemon so we can track
+			// Since= across reconnects, even if the operator didn't ask to
+			// have them printed.
+			Timestamps: true,
+			Since:      since,
+		}
+
+		reader, err := d.client.ContainerLogs(ctx, containerID, logsOptions)
+		if err != nil {
+			return fmt.Errorf("failed to get container logs: %w", err)
+		}
+
+		stdoutWriter := &timestampedLogWriter{dest: os.Stdout, showTimestamps: opts.Timestamps, lastSeen: &since}
+		stderrWriter := &timestampedLogWriter{dest: os.Stderr, showTimestamps: opts.Timestamps, lastSeen: &since}
+
+		_, copyErr := stdcopy.StdCopy(stdoutWriter, stderrWriter, reader)
+		reader.Close()
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		if copyErr != nil && copyErr != io.EOF {
+			return fmt.Errorf("failed to stream container logs: %w", copyErr)
+		}
+
+		running, err := d.isContainerRunning(ctx, containerID)
+		if err != nil {
+			return err
+		}
+		if !running {
+			return nil
+		}
+
+		fmt.Printf("Log stream for %s ended unexpectedly while container is still running; resuming from %s\n", containerID[:12], since)
+		time.Sleep(time.Second)
+	}
+}
+
+// timestampedLogWriter writes Docker's RFC3339Nano-timestamped log lines to
+// dest, optionally stripping the timestamp prefix, and always records the
+// most recent timestamp seen so StreamLogs can resume with Since=<ts> after
+// a reconnect.
+type timestampedLogWriter struct {
+	dest           io.Writer
+	showTimestamps bool
+	lastSeen       *string
+	buf            strings.Builder
+}
+
+func (w *timestampedLogWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	w.buf.Write(p)
+
+	for {
+		data := w.buf.String()
+		idx := strings.IndexByte(data, '\n')
+		if idx < 0 {
+			break
+		}
+
+		line := data[:idx]
+		w.buf.Reset()
+		w.buf.WriteString(data[idx+1:])
+
+		timestamp, rest, ok := strings.Cut(line, " ")
+		if ok {
+			*w.lastSeen = timestamp
+			if w.showTimestamps {
+				fmt.Fprintln(w.dest, line)
+			} else {
+				fmt.Fprintln(w.dest, rest)
+			}
+		} else {
+			fmt.Fprintln(w.dest, line)
+		}
 	}
-	defer reader.Close()
 
-	_, err = io.Copy(os.Stdout, reader)
-	return err
+	return n, nil
 }
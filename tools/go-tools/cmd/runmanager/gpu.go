@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// GPUOptions is the launcher's parsed form of the Docker `--gpus` syntax:
+// "all", "count=2", "device=UUID-...,UUID-...", or
+// "capabilities=compute,utility,count=1".
+type GPUOptions struct {
+	Count        int // -1 means "all"
+	DeviceIDs    []string
+	Capabilities []string
+}
+
+// ParseGPUFlag parses the value of --gpus into a GPUOptions.
+func ParseGPUFlag(spec string) (*GPUOptions, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	opts := &GPUOptions{Count: -1, Capabilities: []string{"gpu"}}
+
+	if spec == "all" {
+		return opts, nil
+	}
+
+	// A bare value with no "key=" continues the list of the preceding
+	// device= or capabilities= field, e.g. "device=UUID-a,UUID-b".
+	listKey := ""
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		switch {
+		case field == "":
+			continue
+		case strings.HasPrefix(field, "count="):
+			count, err := strconv.Atoi(strings.TrimPrefix(field, "count="))
+			if err != nil {
+				return nil, fmt.Errorf("invalid --gpus count: %w", err)
+			}
+			opts.Count = count
+			listKey = ""
+		case strings.HasPrefix(field, "device="):
+			opts.Count = 0
+			opts.DeviceIDs = strings.Split(strings.TrimPrefix(field, "device="), ",")
+			listKey = "device"
+		case strings.HasPrefix(field, "capabilities="):
+			opts.Capabilities = strings.Split(strings.TrimPrefix(field, "capabilities="), ",")
+			listKey = "capabilities"
+		case !strings.Contains(field, "=") && listKey == "device":
+			opts.DeviceIDs = append(opts.DeviceIDs, field)
+		case !strings.Contains(field, "=") && listKey == "capabilities":
+			opts.Capabilities = append(opts.Capabilities, field)
+		default:
+			return nil, fmt.Errorf("unrecognized --gpus field %q", field)
+		}
+	}
+
+	return opts, nil
+}
+
+// GPURequirement is the per-run GPU resource requirement published by the
+// coordinator for a run (see CoordinatorInstanceData.GPURequirement).
+type GPURequirement struct {
+	Count      int32
+	MinVRAMMiB uint64
+	Capability string
+}
+
+// gpuInventoryEntry describes one GPU detected locally via nvidia-smi.
+type gpuInventoryEntry struct {
+	Name     string
+	VRAMMiB  uint64
+}
+
+// queryLocalGPUInventory shells out to nvidia-smi to enumerate the GPUs
+// visible on this host, so we can fail fast before pulling a multi-GB image
+// onto a host that can't satisfy the run's GPU requirement.
+func queryLocalGPUInventory() ([]gpuInventoryEntry, error) {
+	cmd := exec.Command("nvidia-smi", "--query-gpu=name,memory.total", "--format=csv,noheader,nounits")
+	out, err := cmd.Output()
+	if err != nil {
+		if _, ok := err.(*exec.Error); ok {
+			return nil, fmt.Errorf("nvidia-smi not found; is the NVIDIA driver (and for containers, the NVIDIA container toolkit) installed? %w", err)
+		}
+		return nil, fmt.Errorf("failed to query GPU inventory: %w", err)
+	}
+
+	var inventory []gpuInventoryEntry
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, ",", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		vram, err := strconv.ParseUint(strings.TrimSpace(fields[1]), 10, 64)
+		if err != nil {
+			continue
+		}
+		inventory = append(inventory, gpuInventoryEntry{
+			Name:    strings.TrimSpace(fields[0]),
+			VRAMMiB: vram,
+		})
+	}
+
+	return inventory, nil
+}
+
+// CheckGPURequirement verifies the local host's GPU inventory satisfies the
+// run's on-chain GPU requirement, failing fast before the image is pulled.
+// It checks the GPU count and per-GPU VRAM only; req.Capability is not
+// checked against the local GPUs.
+func CheckGPURequirement(req *GPURequirement) error {
+	if req == nil {
+		return nil
+	}
+
+	inventory, err := queryLocalGPUInventory()
+	if err != nil {
+		return err
+	}
+
+	if int32(len(inventory)) < req.Count {
+		return fmt.Errorf("run requires %d GPU(s), but only %d were found on this host", req.Count, len(inventory))
+	}
+
+	satisfying := 0
+	for _, gpu := range inventory {
+		if gpu.VRAMMiB >= req.MinVRAMMiB {
+			satisfying++
+		}
+	}
+	if int32(satisfying) < req.Count {
+		return fmt.Errorf("run requires %d GPU(s) with at least %d MiB VRAM, but only %d of %d local GPUs qualify", req.Count, req.MinVRAMMiB, satisfying, len(inventory))
+	}
+
+	return nil
+}
@@ -7,7 +7,9 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/gagliardetto/solana-go"
 )
@@ -31,6 +33,13 @@ func main() {
 	var envVars envVarsFlag
 	flag.Var(&envVars, "env", "Environment variable in KEY=VALUE format (--env ENV_1=val --env ENV_2=val ... etc)")
 	background := flag.Bool("background", false, "Run container in background")
+	runtime := flag.String("runtime", "auto", "Container runtime to use: docker, podman, or auto (probe the host)")
+	gpus := flag.String("gpus", "", `GPU devices to request, Docker syntax: "all", "count=2", "device=UUID-...", or "capabilities=compute,utility,count=1"`)
+	logTimestamps := flag.Bool("log-timestamps", false, "Prefix streamed log lines with their timestamp")
+	logTail := flag.String("log-tail", "all", `Number of lines to show from the end of the logs, or "all"`)
+	stopTimeout := flag.Duration("stop-timeout", 30*time.Second, "How long to wait for a container to stop gracefully before it is killed")
+	rm := flag.Bool("rm", false, "Remove containers once they've stopped")
+	requireDigest := flag.String("require-digest", "", "Pin the image to this exact sha256:... digest, rejecting any other content for the resolved tag")
 
 	flag.Parse()
 
@@ -58,23 +67,60 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := run(*runID, *rpc, *wsRpc, *walletPath, envVars, *background); err != nil {
+	gpuOpts, err := ParseGPUFlag(*gpus)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	logOpts := LogOptions{Timestamps: *logTimestamps, Tail: *logTail}
+
+	if err := run(*runID, *rpc, *wsRpc, *walletPath, *runtime, envVars, *background, gpuOpts, logOpts, *stopTimeout, *rm, *requireDigest); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func run(runID, rpc, wsRpc, walletPath string, envVars []string, background bool) error {
+func run(runID, rpc, wsRpc, walletPath, runtimeKind string, envVars []string, background bool, gpuOpts *GPUOptions, logOpts LogOptions, stopTimeout time.Duration, rm bool, requireDigest string) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// graceful shutdown
-	sigChan := make(chan os.Signal, 1)
+	// Tracks every container we've started so they can all be stopped (or
+	// force-killed) concurrently on shutdown, even if a hot-restart has one
+	// retiring in the background while its replacement is already running.
+	var containersMu sync.Mutex
+	activeContainers := make(map[string]bool)
+
+	var runtimeImpl ContainerRuntime
+
+	// graceful shutdown: first signal asks the running container(s) to stop
+	// within stopTimeout; a second signal escalates straight to SIGKILL.
+	sigChan := make(chan os.Signal, 2)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-sigChan
-		fmt.Println("\nReceived interrupt signal, shutting down...")
+		fmt.Println("\nReceived interrupt signal, shutting down gracefully (press again to force kill)...")
 		cancel()
+
+		<-sigChan
+		fmt.Println("\nReceived second interrupt signal, force killing containers...")
+		containersMu.Lock()
+		ids := make([]string, 0, len(activeContainers))
+		for id := range activeContainers {
+			ids = append(ids, id)
+		}
+		containersMu.Unlock()
+
+		killCtx, killCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer killCancel()
+		for _, id := range ids {
+			if runtimeImpl != nil {
+				if err := runtimeImpl.KillContainer(killCtx, id); err != nil {
+					fmt.Fprintf(os.Stderr, "Error force killing container %s: %v\n", id, err)
+				}
+			}
+		}
+		os.Exit(1)
 	}()
 
 	// Read wallet private key
@@ -93,7 +139,7 @@ func run(runID, rpc, wsRpc, walletPath string, envVars []string, background bool
 	// This is the on-chain program ID from solana-coordinator/src/lib.rs
 	coordinatorProgramID := solana.MustPublicKeyFromBase58("HR8RN2TP9E9zsi2kjhvPbirJWA1R6L6ruf4xNNGpjU5Y")
 
-	coordinator := NewCoordinatorClient(rpc, coordinatorProgramID)
+	coordinator := NewCoordinatorClient(rpc, wsRpc, coordinatorProgramID)
 	dockerTag, err := coordinator.GetDockerTagForRun(runID)
 	if err != nil {
 		return fmt.Errorf("failed to get docker tag for run: %w", err)
@@ -107,17 +153,21 @@ func run(runID, rpc, wsRpc, walletPath string, envVars []string, background bool
 	}
 	fmt.Printf("Required version: %s\n", version)
 
-	// Initialize Docker manager
-	dockerMgr, err := NewDockerManager()
+	// Fail fast if this host can't satisfy the run's GPU requirement
+	gpuRequirement, err := coordinator.GetGPURequirement(runID)
 	if err != nil {
-		return fmt.Errorf("failed to initialize Docker manager: %w", err)
+		return fmt.Errorf("failed to fetch GPU requirement: %w", err)
+	}
+	if err := CheckGPURequirement(gpuRequirement); err != nil {
+		return fmt.Errorf("host does not satisfy run's GPU requirement: %w", err)
 	}
-	defer dockerMgr.Close()
 
-	// Always pull the image to ensure we have the correct version
-	if err := dockerMgr.PullImage(ctx, dockerTag); err != nil {
-		return fmt.Errorf("failed to pull image: %w", err)
+	// Initialize the container runtime backend (Docker or Podman)
+	runtimeImpl, err = NewContainerRuntime(runtimeKind)
+	if err != nil {
+		return fmt.Errorf("failed to initialize container runtime: %w", err)
 	}
+	defer runtimeImpl.Close()
 
 	allEnvVars := []string{
 		// Required by train_entrypoint.sh
@@ -129,21 +179,140 @@ func run(runID, rpc, wsRpc, walletPath string, envVars []string, background bool
 	}
 	allEnvVars = append(allEnvVars, envVars...)
 
-	// Run the container
-	containerID, err := dockerMgr.RunContainer(ctx, dockerTag, allEnvVars)
+	startContainer := func(tag string) (string, error) {
+		if err := runtimeImpl.PullImage(ctx, tag); err != nil {
+			return "", fmt.Errorf("failed to pull image: %w", err)
+		}
+
+		pinnedRef := tag
+		digest, err := runtimeImpl.ResolveDigest(ctx, tag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to resolve digest for %s: %v\n", tag, err)
+			if requireDigest != "" {
+				return "", fmt.Errorf("cannot enforce --require-digest: %w", err)
+			}
+		} else {
+			if requireDigest != "" && !strings.HasSuffix(digest, requireDigest) {
+				return "", fmt.Errorf("resolved digest for %s (%s) does not match --require-digest %s", tag, digest, requireDigest)
+			}
+			pinnedRef = digest
+		}
+
+		containerID, err := runtimeImpl.RunContainer(ctx, pinnedRef, allEnvVars, gpuOpts)
+		if err != nil {
+			return "", fmt.Errorf("failed to run container: %w", err)
+		}
+
+		containersMu.Lock()
+		activeContainers[containerID] = true
+		containersMu.Unlock()
+
+		if background {
+			fmt.Printf("\nContainer is running in the background: %s\n", containerID[:12])
+		} else {
+			go func() {
+				if err := runtimeImpl.StreamLogs(ctx, containerID, logOpts); err != nil && ctx.Err() == nil {
+					fmt.Fprintf(os.Stderr, "Error: failed to stream logs: %v\n", err)
+				}
+			}()
+		}
+
+		return containerID, nil
+	}
+
+	// retireContainer stops (and optionally removes) an outdated container
+	// in the background, so a hot-restart doesn't block on the old
+	// container's shutdown before the replacement can start serving.
+	retireContainer := func(containerID string) {
+		go func() {
+			stopCtx, stopCancel := context.WithTimeout(context.Background(), stopTimeout)
+			defer stopCancel()
+			if err := runtimeImpl.StopContainer(stopCtx, containerID, stopTimeout); err != nil {
+				fmt.Fprintf(os.Stderr, "Error stopping container %s: %v\n", containerID, err)
+			}
+
+			containersMu.Lock()
+			delete(activeContainers, containerID)
+			containersMu.Unlock()
+
+			if rm {
+				if err := runtimeImpl.RemoveContainer(context.Background(), containerID); err != nil {
+					fmt.Fprintf(os.Stderr, "Error removing container %s: %v\n", containerID, err)
+				}
+			}
+		}()
+	}
+
+	containerID, err := startContainer(dockerTag)
 	if err != nil {
-		return fmt.Errorf("failed to run container: %w", err)
+		return err
 	}
 
-	if background {
-		fmt.Printf("\nContainer is running in the background.\n")
-		fmt.Printf("To view logs: docker logs -f %s\n", containerID[:12])
-		fmt.Printf("To stop: docker stop %s\n", containerID[:12])
-	} else {
-		if err := dockerMgr.StreamLogs(ctx, containerID); err != nil {
-			return fmt.Errorf("failed to stream logs: %w", err)
-		}
+	// Watch the coordinator account for changes and hot-restart the
+	// container whenever the resolved docker tag changes, so the launcher
+	// tracks on-chain configuration updates instead of exiting when a new
+	// client version is published mid-run.
+	updates, err := coordinator.WatchCoordinator(ctx, runID)
+	if err != nil {
+		return fmt.Errorf("failed to watch coordinator account: %w", err)
 	}
 
-	return nil
+	currentTag := dockerTag
+	for {
+		select {
+		case <-ctx.Done():
+			containersMu.Lock()
+			ids := make([]string, 0, len(activeContainers))
+			for id := range activeContainers {
+				ids = append(ids, id)
+			}
+			containersMu.Unlock()
+
+			// Stop every still-active container concurrently rather than
+			// serially, so shutdown time is bounded by the slowest single
+			// container rather than their sum.
+			var wg sync.WaitGroup
+			for _, id := range ids {
+				wg.Add(1)
+				go func(id string) {
+					defer wg.Done()
+					stopCtx, stopCancel := context.WithTimeout(context.Background(), stopTimeout)
+					defer stopCancel()
+					if err := runtimeImpl.StopContainer(stopCtx, id, stopTimeout); err != nil {
+						fmt.Fprintf(os.Stderr, "Error stopping container %s during shutdown: %v\n", id, err)
+						return
+					}
+					if rm {
+						if err := runtimeImpl.RemoveContainer(context.Background(), id); err != nil {
+							fmt.Fprintf(os.Stderr, "Error removing container %s: %v\n", id, err)
+						}
+					}
+				}(id)
+			}
+			wg.Wait()
+			return nil
+
+		case instance, ok := <-updates:
+			if !ok {
+				return nil
+			}
+
+			newTag := dockerTagForInstance(&instance)
+			if newTag == currentTag {
+				continue
+			}
+
+			fmt.Printf("Coordinator published a new docker tag (%s -> %s), restarting container...\n", currentTag, newTag)
+			outgoingContainerID := containerID
+
+			newContainerID, err := startContainer(newTag)
+			if err != nil {
+				return fmt.Errorf("failed to start replacement container: %w", err)
+			}
+			retireContainer(outgoingContainerID)
+
+			containerID = newContainerID
+			currentTag = newTag
+		}
+	}
 }
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/containers/podman/v5/libpod/define"
+	"github.com/containers/podman/v5/pkg/bindings"
+	"github.com/containers/podman/v5/pkg/bindings/containers"
+	"github.com/containers/podman/v5/pkg/bindings/images"
+	"github.com/containers/podman/v5/pkg/specgen"
+	ocispec "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// PodmanManager runs the training client under rootless Podman, talking to
+// libpod's native API over the user's per-session unix socket.
+type PodmanManager struct {
+	conn context.Context
+}
+
+func NewPodmanManager() (*PodmanManager, error) {
+	socketPath := podmanSocketPath()
+	if _, err := os.Stat(socketPath); err != nil {
+		return nil, fmt.Errorf("podman socket not found at %s (is `podman system service` running?): %w", socketPath, err)
+	}
+
+	conn, err := bindings.NewConnection(context.Background(), "unix://"+socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Podman socket: %w", err)
+	}
+
+	return &PodmanManager{conn: conn}, nil
+}
+
+// withConn returns ctx augmented with the bindings.Connection p.conn carries
+// (podman/v5's bindings calls look it up via bindings.GetClient(ctx)), while
+// deferring Done/Err/Deadline to ctx itself rather than p.conn. p.conn is
+// rooted in context.Background() once at connect time, so using it directly
+// as the bindings call argument (as a plain alias for p.conn) would silently
+// swallow every caller-supplied cancellation/timeout.
+func (p *PodmanManager) withConn(ctx context.Context) context.Context {
+	return connScopedContext{Context: ctx, values: p.conn}
+}
+
+// connScopedContext takes its deadline/cancellation from Context and its
+// Value lookups from values, so a bindings call can see both the caller's
+// ctx and the connection bindings.NewConnection stored on a separate ctx.
+type connScopedContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c connScopedContext) Value(key any) any {
+	return c.values.Value(key)
+}
+
+func (p *PodmanManager) Close() error {
+	// The bindings connection is plain context plumbing; there is no
+	// underlying handle to release beyond the HTTP client it carries.
+	return nil
+}
+
+func (p *PodmanManager) PullImage(ctx context.Context, imageName string) error {
+	fmt.Printf("Pulling image: %s\n", imageName)
+
+	if _, err := images.Pull(p.withConn(ctx), imageName, nil); err != nil {
+		return fmt.Errorf("failed to pull image: %w", err)
+	}
+
+	fmt.Printf("Successfully pulled image: %s\n", imageName)
+	return nil
+}
+
+// ResolveDigest returns "<repo>@sha256:<digest>" for an already-pulled tag,
+// so the caller can pin the exact image content rather than a mutable tag.
+func (p *PodmanManager) ResolveDigest(ctx context.Context, imageName string) (string, error) {
+	inspect, err := images.GetImage(p.withConn(ctx), imageName, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", imageName, err)
+	}
+
+	if len(inspect.RepoDigests) == 0 {
+		return "", fmt.Errorf("image %s has no RepoDigests (was it pulled from a registry?)", imageName)
+	}
+
+	return inspect.RepoDigests[0], nil
+}
+
+func (p *PodmanManager) RunContainer(ctx context.Context, imageName string, envVars []string, gpus *GPUOptions) (string, error) {
+	fmt.Printf("Creating container from image: %s\n", imageName)
+	fmt.Printf("Environment variables: %v\n", envVars)
+
+	spec := specgen.NewSpecGenerator(imageName, false)
+	spec.Env = envToMap(envVars)
+
+	if gpus != nil {
+		// Rootless Podman has no DeviceRequests equivalent; GPUs are
+		// exposed as CDI devices instead (nvidia-ctk generates the
+		// nvidia.com/gpu=* device specs under /etc/cdi).
+		cdiDevice := "nvidia.com/gpu=all"
+		if len(gpus.DeviceIDs) > 0 {
+			cdiDevice = fmt.Sprintf("nvidia.com/gpu=%s", strings.Join(gpus.DeviceIDs, ","))
+		}
+		fmt.Printf("Requesting CDI device: %s\n", cdiDevice)
+		// There is no dedicated CDI field on SpecGenerator: the create
+		// path (ExtractCDIDevices) scans Devices for CDI-qualified names
+		// and routes them to libpod.WithCDI itself.
+		spec.Devices = append(spec.Devices, ocispec.LinuxDevice{Path: cdiDevice})
+	}
+
+	resp, err := containers.CreateWithSpec(p.withConn(ctx), spec, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create container: %w", err)
+	}
+	containerID := resp.ID
+	fmt.Printf("Created container: %s\n", containerID)
+
+	if err := containers.Start(p.withConn(ctx), containerID, nil); err != nil {
+		return "", fmt.Errorf("failed to start container: %w", err)
+	}
+
+	fmt.Printf("Started container: %s\n", containerID)
+	return containerID, nil
+}
+
+// StopContainer stops a running container, waiting up to timeout for it to
+// exit on its own (a zero timeout defers to libpod's own default grace
+// period), matching Podman's own "optional stop timeout" semantics.
+func (p *PodmanManager) StopContainer(ctx context.Context, containerID string, timeout time.Duration) error {
+	fmt.Printf("Stopping container: %s (timeout: %s)\n", containerID, timeout)
+
+	stopOptions := new(containers.StopOptions)
+	if timeout > 0 {
+		secs := uint(timeout.Seconds())
+		stopOptions = stopOptions.WithTimeout(secs)
+	}
+
+	if err := containers.Stop(p.withConn(ctx), containerID, stopOptions); err != nil {
+		return fmt.Errorf("failed to stop container: %w", err)
+	}
+
+	if _, err := containers.Wait(p.withConn(ctx), containerID, new(containers.WaitOptions).WithCondition([]define.ContainerStatus{define.ContainerStateExited, define.ContainerStateStopped})); err != nil {
+		return fmt.Errorf("failed waiting for container to stop: %w", err)
+	}
+
+	return nil
+}
+
+// KillContainer immediately sends SIGKILL, for escalation when a second
+// shutdown signal arrives while a graceful stop is still in flight.
+func (p *PodmanManager) KillContainer(ctx context.Context, containerID string) error {
+	fmt.Printf("Force killing container: %s\n", containerID)
+	if err := containers.Kill(p.withConn(ctx), containerID, new(containers.KillOptions).WithSignal("SIGKILL")); err != nil {
+		return fmt.Errorf("failed to kill container: %w", err)
+	}
+	return nil
+}
+
+// RemoveContainer deletes a stopped container.
+func (p *PodmanManager) RemoveContainer(ctx context.Context, containerID string) error {
+	fmt.Printf("Removing container: %s\n", containerID)
+	reports, err := containers.Remove(p.withConn(ctx), containerID, nil)
+	if err != nil {
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+	for _, r := range reports {
+		if r.Err != nil {
+			return fmt.Errorf("failed to remove container: %w", r.Err)
+		}
+	}
+	return nil
+}
+
+func (p *PodmanManager) StreamLogs(ctx context.Context, containerID string, opts LogOptions) error {
+	stdout := make(chan string)
+	stderr := make(chan string)
+
+	logOpts := new(containers.LogOptions).
+		WithStdout(true).
+		WithStderr(true).
+		WithFollow(true).
+		WithTimestamps(opts.Timestamps)
+	if opts.Tail != "" {
+		logOpts = logOpts.WithTail(opts.Tail)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- containers.Logs(p.withConn(ctx), containerID, logOpts, stdout, stderr)
+	}()
+
+	for {
+		select {
+		case line, ok := <-stdout:
+			if !ok {
+				stdout = nil
+				break
+			}
+			fmt.Fprintln(os.Stdout, line)
+		case line, ok := <-stderr:
+			if !ok {
+				stderr = nil
+				break
+			}
+			fmt.Fprintln(os.Stderr, line)
+		case err := <-errChan:
+			if err != nil && err != io.EOF {
+				return fmt.Errorf("failed to stream container logs: %w", err)
+			}
+			return nil
+		}
+	}
+}
+
+// envToMap converts the launcher's "KEY=VALUE" slice into the map form
+// specgen.SpecGenerator expects.
+func envToMap(envVars []string) map[string]string {
+	env := make(map[string]string, len(envVars))
+	for _, kv := range envVars {
+		for i := 0; i < len(kv); i++ {
+			if kv[i] == '=' {
+				env[kv[:i]] = kv[i+1:]
+				break
+			}
+		}
+	}
+	return env
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ContainerRuntime abstracts the OCI runtime used to launch the training
+// client container, so the launcher isn't hardwired to the Docker Engine.
+type ContainerRuntime interface {
+	PullImage(ctx context.Context, imageName string) error
+	// ResolveDigest returns the immutable "image@sha256:..." reference for
+	// an already-pulled tag, so a mutable tag can never cause two nodes on
+	// the same run to execute different binaries.
+	ResolveDigest(ctx context.Context, imageName string) (string, error)
+	RunContainer(ctx context.Context, imageName string, envVars []string, gpus *GPUOptions) (string, error)
+	StreamLogs(ctx context.Context, containerID string, opts LogOptions) error
+	// StopContainer asks the container to exit, waiting up to timeout
+	// before giving up. A zero timeout uses the runtime's own default
+	// stop grace period rather than forcing an immediate kill.
+	StopContainer(ctx context.Context, containerID string, timeout time.Duration) error
+	// KillContainer immediately sends SIGKILL, for escalation when a
+	// second shutdown signal arrives mid-stop.
+	KillContainer(ctx context.Context, containerID string) error
+	// RemoveContainer deletes a stopped container, e.g. after --rm.
+	RemoveContainer(ctx context.Context, containerID string) error
+	Close() error
+}
+
+// LogOptions controls how StreamLogs presents the container's output.
+type LogOptions struct {
+	// Timestamps prefixes each printed line with its RFC3339Nano timestamp.
+	Timestamps bool
+	// Tail limits the initial backlog fetched, e.g. "100" or "all".
+	Tail string
+}
+
+// podmanSocketPath returns the path to the user's rootless Podman API
+// socket, following the same $XDG_RUNTIME_DIR convention Podman itself uses.
+func podmanSocketPath() string {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = fmt.Sprintf("/run/user/%d", os.Getuid())
+	}
+	return filepath.Join(runtimeDir, "podman", "podman.sock")
+}
+
+// detectRuntime picks a backend when the caller didn't force one: it prefers
+// a reachable Podman socket (the common case on rootless hosts) and falls
+// back to the Docker Engine otherwise.
+func detectRuntime() string {
+	if info, err := os.Stat(podmanSocketPath()); err == nil && info.Mode()&os.ModeSocket != 0 {
+		return "podman"
+	}
+	return "docker"
+}
+
+// NewContainerRuntime constructs the requested backend. kind may be
+// "docker", "podman", or "auto" (the default) to probe the host.
+func NewContainerRuntime(kind string) (ContainerRuntime, error) {
+	if kind == "" || kind == "auto" {
+		kind = detectRuntime()
+	}
+
+	switch kind {
+	case "docker":
+		return NewDockerManager()
+	case "podman":
+		return NewPodmanManager()
+	default:
+		return nil, fmt.Errorf("unknown container runtime %q (expected docker, podman, or auto)", kind)
+	}
+}
@@ -0,0 +1,38 @@
+// Package timerpool pools *time.Timer values for code that repeatedly
+// selects on a timeout in a long-running loop (a per-client metrics poll, a
+// coordinator readiness wait) where time.After would otherwise allocate a
+// new timer, and its channel, on every iteration.
+package timerpool
+
+import (
+	"sync"
+	"time"
+)
+
+var pool = sync.Pool{
+	New: func() any {
+		t := time.NewTimer(time.Hour)
+		t.Stop()
+		return t
+	},
+}
+
+// Get returns a *time.Timer from the pool, reset to fire after d. Callers
+// must return it via Put once they're done selecting on it.
+func Get(d time.Duration) *time.Timer {
+	t := pool.Get().(*time.Timer)
+	t.Reset(d)
+	return t
+}
+
+// Put stops t, draining its channel if it had already fired, and returns it
+// to the pool. Callers must not use t after calling Put.
+func Put(t *time.Timer) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	pool.Put(t)
+}
@@ -0,0 +1,28 @@
+package timerpool
+
+import (
+	"testing"
+	"time"
+)
+
+// BenchmarkGetPut measures the pooled Get/Put round trip against a bare
+// time.After, the pattern it replaces in the per-client metrics poll and
+// coordinator readiness wait. Run with -benchmem to see the allocation
+// counts scaling to hundreds of clients is meant to avoid.
+func BenchmarkGetPut(b *testing.B) {
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			t := Get(time.Millisecond)
+			<-t.C
+			Put(t)
+		}
+	})
+
+	b.Run("bare timeAfter", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			<-time.After(time.Millisecond)
+		}
+	})
+}